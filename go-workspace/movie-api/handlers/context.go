@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestContext returns the context for a request's database and TMDB work,
+// with a deadline when timeout is positive. fasthttp doesn't tell handlers
+// when the client disconnects, so only the timeout cuts the work short
+func requestContext(c *fiber.Ctx, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := c.UserContext()
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// serverErrorResponse reports a failure that isn't the caller's fault: 504
+// when the request ran out of time, 500 otherwise
+func serverErrorResponse(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+			"error": "Request timed out",
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -1,28 +1,203 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rohankarmacharya/movie-lib/client"
 	"github.com/rohankarmacharya/movie-lib/models"
 	"github.com/rohankarmacharya/movie-lib/repository"
-	"github.com/rohankarmacharya/movie-lib/service"
-	"gorm.io/gorm"
 )
 
+// TMDBClient is the part of the TMDB client the /api/tmdb handlers use.
+// *client.TMDBClient implements it
+type TMDBClient interface {
+	SearchMoviesPage(ctx context.Context, query string, page int) (*client.MoviePage, error)
+	FetchListPage(ctx context.Context, q client.ListQuery, page int) (*client.MoviePage, error)
+	FetchMovieDetails(ctx context.Context, movieID string) (*models.Movie, error)
+	CacheStats() client.CacheStats
+}
+
+// SyncJobs runs TMDB syncs in the background. *service.SyncJobs implements it
+type SyncJobs interface {
+	Start(ctx context.Context, source string) (*models.SyncJob, error)
+	Get(ctx context.Context, id uint) (*models.SyncJob, error)
+	List(ctx context.Context, limit int) ([]models.SyncJob, error)
+}
+
+// SyncSchedules lists and controls the scheduled syncs. *service.Scheduler
+// implements it
+type SyncSchedules interface {
+	List(ctx context.Context) ([]models.SyncSchedule, error)
+	Pause(ctx context.Context, name string) (*models.SyncSchedule, error)
+	Resume(ctx context.Context, name string) (*models.SyncSchedule, error)
+	Trigger(ctx context.Context, name string) (*models.SyncJob, error)
+}
+
+// MovieHandler serves the movie, genre, people and TMDB endpoints
+type MovieHandler struct {
+	repo      repository.Repository
+	tmdb      TMDBClient
+	jobs      SyncJobs
+	schedules SyncSchedules
+
+	requestTimeout time.Duration
+	mergePolicy    models.MergePolicy
+}
+
+// DefaultRequestTimeout is the default deadline for the work behind a request
+const DefaultRequestTimeout = 15 * time.Second
+
+// Option configures a MovieHandler
+type Option func(*MovieHandler)
+
+// WithRequestTimeout bounds the database and TMDB work of each request;
+// 0 means no deadline
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(h *MovieHandler) {
+		h.requestTimeout = timeout
+	}
+}
+
+// WithMergePolicy sets the merge policy edits are recorded for, so that
+// under lock-field editing a field locks it
+func WithMergePolicy(policy models.MergePolicy) Option {
+	return func(h *MovieHandler) {
+		if policy.Valid() {
+			h.mergePolicy = policy
+		}
+	}
+}
+
+// NewMovieHandler creates a handler backed by repo, tmdb, jobs and schedules
+func NewMovieHandler(repo repository.Repository, tmdb TMDBClient, jobs SyncJobs, schedules SyncSchedules, opts ...Option) *MovieHandler {
+	h := &MovieHandler{
+		repo:           repo,
+		tmdb:           tmdb,
+		jobs:           jobs,
+		schedules:      schedules,
+		requestTimeout: DefaultRequestTimeout,
+		mergePolicy:    models.DefaultMergePolicy,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// movieRequest is the body of POST and PUT /api/movies. Fields left out of
+// a PUT keep their stored values
 type movieRequest struct {
-	ExternalID  string   `json:"external_id"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	PosterPath  string   `json:"poster_path"`
-	ReleaseDate string   `json:"release_date"`
-	Rating      *float64 `json:"rating"`
+	ExternalID       *string  `json:"external_id"`
+	IMDbID           *string  `json:"imdb_id"`
+	Title            *string  `json:"title"`
+	OriginalTitle    *string  `json:"original_title"`
+	OriginalLanguage *string  `json:"original_language"`
+	Tagline          *string  `json:"tagline"`
+	Description      *string  `json:"description"`
+	Status           *string  `json:"status"`
+	PosterPath       *string  `json:"poster_path"`
+	BackdropPath     *string  `json:"backdrop_path"`
+	ReleaseDate      *string  `json:"release_date"`
+	Runtime          *int     `json:"runtime"`
+	Budget           *int64   `json:"budget"`
+	Revenue          *int64   `json:"revenue"`
+	Rating           *float64 `json:"rating"`
+	// GenreIDs replaces the movie's genres when given
+	GenreIDs []uint `json:"genre_ids"`
+	// LockedFields replaces the movie's locks when given
+	LockedFields []string `json:"locked_fields"`
+}
+
+// apply copies the fields the request carries onto movie, except genres,
+// which need the catalogue. Its errors are meant for the client
+func (req *movieRequest) apply(movie *models.Movie) error {
+	if name := unknownMovieField(req.LockedFields); name != "" {
+		return fmt.Errorf("Unknown field %q in locked_fields, expected one of %s", name, strings.Join(models.MovieFields(), ", "))
+	}
+	if req.ReleaseDate != nil {
+		var releaseDate time.Time
+		if *req.ReleaseDate != "" {
+			parsed, err := time.Parse("2006-01-02", *req.ReleaseDate)
+			if err != nil {
+				return errors.New("Invalid release_date format, expected YYYY-MM-DD")
+			}
+			releaseDate = parsed
+		}
+		movie.ReleaseDate = releaseDate
+	}
+
+	setField(&movie.ExternalID, req.ExternalID)
+	setField(&movie.IMDbID, req.IMDbID)
+	setField(&movie.Title, req.Title)
+	setField(&movie.OriginalTitle, req.OriginalTitle)
+	setField(&movie.OriginalLanguage, req.OriginalLanguage)
+	setField(&movie.Tagline, req.Tagline)
+	setField(&movie.Description, req.Description)
+	setField(&movie.Status, req.Status)
+	setField(&movie.PosterPath, req.PosterPath)
+	setField(&movie.BackdropPath, req.BackdropPath)
+	setField(&movie.Runtime, req.Runtime)
+	setField(&movie.Budget, req.Budget)
+	setField(&movie.Revenue, req.Revenue)
+	setField(&movie.Rating, req.Rating)
+	if req.LockedFields != nil {
+		movie.LockedFields = req.LockedFields
+	}
+	return nil
+}
+
+// setField sets *dst to *value when the request carries the value
+func setField[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+// unknownMovieField returns the first name in fields that isn't a lockable
+// movie field, or "" if they all are
+func unknownMovieField(fields []string) string {
+	for _, name := range fields {
+		if !models.ValidMovieField(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// genresByID looks up the catalogue genres with the given IDs. unknown is
+// the first ID the catalogue doesn't have, or 0
+func (h *MovieHandler) genresByID(ctx context.Context, ids []uint) (genres []models.Genre, unknown uint, err error) {
+	all, err := h.repo.GetAllGenres(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]models.Genre, len(all))
+	for _, g := range all {
+		byID[g.ID] = g
+	}
+	genres = make([]models.Genre, 0, len(ids))
+	for _, id := range ids {
+		g, ok := byID[id]
+		if !ok {
+			return nil, id, nil
+		}
+		genres = append(genres, g)
+	}
+	return genres, 0, nil
 }
 
 // GetMovies handles GET /api/movies
-func GetMovies(c *fiber.Ctx) error {
+func (h *MovieHandler) GetMovies(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	var queryParams models.MovieQueryParams
 	if err := c.QueryParser(&queryParams); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -37,19 +212,78 @@ func GetMovies(c *fiber.Ctx) error {
 	if queryParams.Limit < 1 || queryParams.Limit > 100 {
 		queryParams.Limit = 10
 	}
-
-	result, err := repository.GetMoviesWithPagination(queryParams)
+	keys, err := queryParams.SortKeys()
+	if err == nil {
+		_, err = queryParams.PageCursor(keys)
+	}
+	if err == nil {
+		_, err = queryParams.FacetNames()
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch movies",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
+	result, err := h.repo.GetMoviesWithPagination(ctx, queryParams)
+	if err != nil {
+		return serverErrorResponse(c, err, "Failed to fetch movies")
+	}
+
 	return c.JSON(result)
 }
 
+// Limits of GET /api/movies/autocomplete
+const (
+	defaultSuggestionsLimit = 8
+	maxSuggestionsLimit     = 20
+)
+
+// AutocompleteMovies handles GET /api/movies/autocomplete?q=&limit=
+func (h *MovieHandler) AutocompleteMovies(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'q' is required",
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultSuggestionsLimit)
+	if limit < 1 || limit > maxSuggestionsLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Limit must be between 1 and %d", maxSuggestionsLimit),
+		})
+	}
+
+	suggestions, err := h.repo.SuggestMovies(ctx, query, limit)
+	if err != nil {
+		return serverErrorResponse(c, err, "Failed to fetch suggestions")
+	}
+
+	return c.JSON(suggestions)
+}
+
+// GetGenres handles GET /api/genres
+func (h *MovieHandler) GetGenres(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	genres, err := h.repo.GetAllGenres(ctx)
+	if err != nil {
+		return serverErrorResponse(c, err, "Failed to fetch genres")
+	}
+
+	return c.JSON(genres)
+}
+
 // GetMovie handles GET /api/movies/:id
-func GetMovie(c *fiber.Ctx) error {
+func (h *MovieHandler) GetMovie(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -57,23 +291,25 @@ func GetMovie(c *fiber.Ctx) error {
 		})
 	}
 
-	movie, err := repository.GetMovieByID(uint(id))
+	movie, err := h.repo.GetMovieByID(ctx, uint(id))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Movie not found",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch movie",
-		})
+		return serverErrorResponse(c, err, "Failed to fetch movie")
 	}
 
 	return c.JSON(movie)
 }
 
-// CreateMovie handles POST /api/movies
-func CreateMovie(c *fiber.Ctx) error {
+// CreateMovie handles POST /api/movies. A movie whose external_id is already
+// stored is a conflict; edits to it go through PUT, which keeps provenance
+func (h *MovieHandler) CreateMovie(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	var req movieRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -81,45 +317,57 @@ func CreateMovie(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Title == "" {
+	if req.Title == nil || *req.Title == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Title is required",
 		})
 	}
-
-	var releaseDate time.Time
-	if req.ReleaseDate != "" {
-		parsed, err := time.Parse("2006-01-02", req.ReleaseDate)
+	var movie models.Movie
+	if err := req.apply(&movie); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if req.GenreIDs != nil {
+		genres, unknown, err := h.genresByID(ctx, req.GenreIDs)
 		if err != nil {
+			return serverErrorResponse(c, err, "Failed to create movie")
+		}
+		if unknown != 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid release_date format, expected YYYY-MM-DD",
+				"error": fmt.Sprintf("Unknown genre ID %d in genre_ids", unknown),
 			})
 		}
-		releaseDate = parsed
+		movie.Genres = genres
 	}
+	h.mergePolicy.RecordEdit(&models.Movie{}, &movie)
 
-	movie := models.Movie{
-		ExternalID:  req.ExternalID,
-		Title:       req.Title,
-		Description: req.Description,
-		PosterPath:  req.PosterPath,
-		ReleaseDate: releaseDate,
-	}
-	if req.Rating != nil {
-		movie.Rating = *req.Rating
+	if movie.ExternalID != "" {
+		existing, err := h.repo.GetMoviesByExternalIDs(ctx, []string{movie.ExternalID})
+		if err != nil {
+			return serverErrorResponse(c, err, "Failed to create movie")
+		}
+		if len(existing) > 0 {
+			c.Location(fmt.Sprintf("/api/movies/%d", existing[0].ID))
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("A movie with external_id %q already exists, update it with PUT /api/movies/%d", movie.ExternalID, existing[0].ID),
+				"movie": existing[0],
+			})
+		}
 	}
 
-	if err := repository.CreateMovie(&movie); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create movie",
-		})
+	if err := h.repo.CreateMovie(ctx, &movie); err != nil {
+		return serverErrorResponse(c, err, "Failed to create movie")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(movie)
 }
 
 // UpdateMovie handles PUT /api/movies/:id
-func UpdateMovie(c *fiber.Ctx) error {
+func (h *MovieHandler) UpdateMovie(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -133,46 +381,61 @@ func UpdateMovie(c *fiber.Ctx) error {
 			"error": "Cannot parse JSON",
 		})
 	}
+	if req.Title != nil && *req.Title == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Title is required",
+		})
+	}
 
-	var releaseDate time.Time
-	if req.ReleaseDate != "" {
-		parsed, err := time.Parse("2006-01-02", req.ReleaseDate)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid release_date format, expected YYYY-MM-DD",
+	stored, err := h.repo.GetMovieByID(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Movie not found",
 			})
 		}
-		releaseDate = parsed
+		return serverErrorResponse(c, err, "Failed to fetch movie")
 	}
 
-	movie := models.Movie{
-		ID:          uint(id),
-		ExternalID:  req.ExternalID,
-		Title:       req.Title,
-		Description: req.Description,
-		PosterPath:  req.PosterPath,
-		ReleaseDate: releaseDate,
+	// Fields the request doesn't carry keep their stored values, so they
+	// aren't recorded as edited
+	movie := *stored
+	if err := req.apply(&movie); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if req.Rating != nil {
-		movie.Rating = *req.Rating
+	if req.GenreIDs != nil {
+		genres, unknown, err := h.genresByID(ctx, req.GenreIDs)
+		if err != nil {
+			return serverErrorResponse(c, err, "Failed to update movie")
+		}
+		if unknown != 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Unknown genre ID %d in genre_ids", unknown),
+			})
+		}
+		movie.Genres = genres
 	}
+	h.mergePolicy.RecordEdit(stored, &movie)
 
-	if err := repository.UpdateMovie(&movie); err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if err := h.repo.UpdateMovie(ctx, &movie); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Movie not found",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update movie",
-		})
+		return serverErrorResponse(c, err, "Failed to update movie")
 	}
 
 	return c.JSON(movie)
 }
 
 // DeleteMovie handles DELETE /api/movies/:id
-func DeleteMovie(c *fiber.Ctx) error {
+func (h *MovieHandler) DeleteMovie(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -180,61 +443,100 @@ func DeleteMovie(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := repository.DeleteMovie(uint(id)); err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if err := h.repo.DeleteMovie(ctx, uint(id)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Movie deleted",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete movie",
-		})
+		return serverErrorResponse(c, err, "Failed to delete movie")
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
-// SyncMovies handles POST /api/movies/sync
-func SyncMovies(c *fiber.Ctx) error {
-	if err := service.SyncWithAPI(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to sync movies from TMDB",
+// SearchTMDBMovies handles GET /api/tmdb/movies/search?query=&page=
+func (h *MovieHandler) SearchTMDBMovies(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	query := c.Query("query")
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'query' is required",
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Movies synced successfully",
-	})
+	page := c.QueryInt("page", 1)
+	if page < 1 || page > client.MaxPage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'page' must be between 1 and 500",
+		})
+	}
+
+	result, err := h.tmdb.SearchMoviesPage(ctx, query, page)
+	if err != nil {
+		return tmdbErrorResponse(c, err, "Failed to search TMDB")
+	}
+
+	return c.JSON(result)
 }
 
-// SearchTMDBMovies handles GET /api/tmdb/movies/search
-func SearchTMDBMovies(c *fiber.Ctx) error {
-	query := c.Query("query")
-	if query == "" {
+// GetTMDBMovieList handles GET /api/tmdb/movies/:list?page= for the lists
+// popular, now_playing, upcoming, top_rated, trending (window=day|week) and
+// discover (year, genres, vote_count.gte, sort_by, with_original_language)
+func (h *MovieHandler) GetTMDBMovieList(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	page := c.QueryInt("page", 1)
+	if page < 1 || page > client.MaxPage {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Query parameter 'query' is required",
+			"error": "Query parameter 'page' must be between 1 and 500",
 		})
 	}
 
-	movies, err := client.SearchMovies(query)
+	params := url.Values{}
+	for name, value := range c.Queries() {
+		if name != "page" {
+			params.Set(name, value)
+		}
+	}
+	q, err := client.ParseListQuery(c.Params("list"), params)
+	if errors.Is(err, client.ErrUnknownList) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown TMDB movie list",
+		})
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to search TMDB",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(movies)
+	result, err := h.tmdb.FetchListPage(ctx, q, page)
+	if err != nil {
+		return tmdbErrorResponse(c, err, "Failed to fetch movie list from TMDB")
+	}
+
+	return c.JSON(result)
 }
 
 // GetTMDBMovieDetails handles GET /api/tmdb/movies/:id
-func GetTMDBMovieDetails(c *fiber.Ctx) error {
+func (h *MovieHandler) GetTMDBMovieDetails(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
 	id := c.Params("id")
-	movie, err := client.FetchMovieDetails(id)
+	movie, err := h.tmdb.FetchMovieDetails(ctx, id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch movie details from TMDB",
-		})
+		return tmdbErrorResponse(c, err, "Failed to fetch movie details from TMDB")
 	}
 
 	return c.JSON(movie)
 }
+
+// GetTMDBCacheStats handles GET /api/tmdb/cache/stats
+func (h *MovieHandler) GetTMDBCacheStats(c *fiber.Ctx) error {
+	return c.JSON(h.tmdb.CacheStats())
+}
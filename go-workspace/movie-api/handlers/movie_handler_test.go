@@ -0,0 +1,201 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"movie-api/handlers"
+	"movie-api/routes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rohankarmacharya/movie-lib/client/tmdbfake"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/service"
+)
+
+// newApp serves the API from an in-memory repository and a fake TMDB
+func newApp(t *testing.T) (*fiber.App, repository.Repository, *tmdbfake.Server) {
+	t.Helper()
+	fake := tmdbfake.NewServer()
+	t.Cleanup(fake.Close)
+	repo := repository.NewMemoryRepository()
+	tmdb := fake.Client()
+	jobs := service.NewSyncJobs(repo, service.NewSyncService(repo, tmdb))
+	t.Cleanup(func() { jobs.Shutdown(t.Context()) })
+
+	app := fiber.New()
+	routes.MovieRoutes(app, handlers.NewMovieHandler(repo, tmdb, jobs, nil))
+	return app, repo, fake
+}
+
+// send makes a request with body encoded as JSON, unless it is nil, and
+// decodes the response into out, unless it is nil
+func send(t *testing.T, app *fiber.App, method, target string, body, out any) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding the response: %v", method, target, err)
+		}
+	}
+	return resp
+}
+
+// moviePage is the response of GET /api/movies
+type moviePage struct {
+	Data  []models.Movie `json:"data"`
+	Total *int64         `json:"total"`
+}
+
+func TestMovieEndpoints(t *testing.T) {
+	app, _, _ := newApp(t)
+
+	body := map[string]any{"external_id": "550", "title": "Fight Club", "release_date": "1999-10-15", "runtime": 139}
+	var created models.Movie
+	resp := send(t, app, http.MethodPost, "/api/movies", body, &created)
+	if resp.StatusCode != http.StatusCreated || created.ID == 0 || created.Title != "Fight Club" {
+		t.Fatalf("POST = %d, %+v; want 201 with the movie", resp.StatusCode, created)
+	}
+	target := "/api/movies/" + strconv.FormatUint(uint64(created.ID), 10)
+
+	resp = send(t, app, http.MethodPost, "/api/movies", body, nil)
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("Location") != target {
+		t.Errorf("POST of a stored external_id = %d, Location %q; want 409 pointing at %s", resp.StatusCode, resp.Header.Get("Location"), target)
+	}
+	for _, bad := range []map[string]any{
+		{"description": "no title"},
+		{"title": "Bad date", "release_date": "15/10/1999"},
+		{"title": "Bad lock", "locked_fields": []string{"nope"}},
+	} {
+		if resp := send(t, app, http.MethodPost, "/api/movies", bad, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %v = %d, want 400", bad, resp.StatusCode)
+		}
+	}
+	if resp := send(t, app, http.MethodPost, "/api/movies", map[string]any{"title": "Memento"}, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("POST without external_id = %d, want 201", resp.StatusCode)
+	}
+
+	var got models.Movie
+	if resp := send(t, app, http.MethodGet, target, nil, &got); resp.StatusCode != http.StatusOK || got.Title != "Fight Club" || got.Runtime != 139 {
+		t.Errorf("GET = %d, %+v; want the created movie", resp.StatusCode, got)
+	}
+	if resp := send(t, app, http.MethodGet, "/api/movies/999", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET of a missing movie = %d, want 404", resp.StatusCode)
+	}
+	if resp := send(t, app, http.MethodGet, "/api/movies/abc", nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET of a bad ID = %d, want 400", resp.StatusCode)
+	}
+
+	var page moviePage
+	resp = send(t, app, http.MethodGet, "/api/movies?sort=title", nil, &page)
+	if resp.StatusCode != http.StatusOK || len(page.Data) != 2 || page.Data[0].Title != "Fight Club" || page.Total == nil || *page.Total != 2 {
+		t.Errorf("GET /api/movies = %d, %+v; want both movies by title", resp.StatusCode, page)
+	}
+	resp = send(t, app, http.MethodGet, "/api/movies?search=memento", nil, &page)
+	if resp.StatusCode != http.StatusOK || len(page.Data) != 1 || page.Data[0].Title != "Memento" {
+		t.Errorf("GET /api/movies?search=memento = %d, %+v; want Memento", resp.StatusCode, page)
+	}
+
+	if resp := send(t, app, http.MethodPut, target, map[string]any{"runtime": 140}, &got); resp.StatusCode != http.StatusOK || got.Runtime != 140 {
+		t.Errorf("PUT = %d, %+v; want the runtime updated", resp.StatusCode, got)
+	}
+	if resp := send(t, app, http.MethodPut, "/api/movies/999", map[string]any{"runtime": 140}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PUT of a missing movie = %d, want 404", resp.StatusCode)
+	}
+	if resp := send(t, app, http.MethodPut, target, map[string]any{"title": ""}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT clearing the title = %d, want 400", resp.StatusCode)
+	}
+
+	if resp := send(t, app, http.MethodDelete, target, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", resp.StatusCode)
+	}
+	if resp := send(t, app, http.MethodGet, target, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestUpdateMovieKeepsFieldsLeftOut(t *testing.T) {
+	app, repo, _ := newApp(t)
+	ctx := t.Context()
+	genres := []models.Genre{{ID: 18, Name: "Drama"}, {ID: 53, Name: "Thriller"}}
+	if err := repo.SaveGenres(ctx, genres); err != nil {
+		t.Fatal(err)
+	}
+	stored := models.Movie{
+		ExternalID:  "550",
+		IMDbID:      "tt0137523",
+		Title:       "Fight Club",
+		Tagline:     "Mischief. Mayhem. Soap.",
+		ReleaseDate: time.Date(1999, 10, 15, 0, 0, 0, 0, time.UTC),
+		Runtime:     139,
+		Budget:      63000000,
+		Revenue:     100853753,
+		PosterPath:  "/pB8BM7pdSp6B6Ih7QZ4DrQ3PmJK.jpg",
+		Genres:      genres[:1],
+	}
+	if err := repo.CreateMovie(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+	target := "/api/movies/" + strconv.FormatUint(uint64(stored.ID), 10)
+
+	var got models.Movie
+	resp := send(t, app, http.MethodPut, target, map[string]any{"title": "Fight Club (1999)"}, &got)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("partial PUT status = %d, want 200", resp.StatusCode)
+	}
+	saved, err := repo.GetMovieByID(ctx, stored.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*models.Movie{&got, saved} {
+		if m.Title != "Fight Club (1999)" {
+			t.Errorf("title = %q, want the new title", m.Title)
+		}
+		if m.ExternalID != stored.ExternalID || m.IMDbID != stored.IMDbID || m.Tagline != stored.Tagline ||
+			m.Budget != stored.Budget || m.Revenue != stored.Revenue || m.PosterPath != stored.PosterPath ||
+			m.Runtime != stored.Runtime || !m.ReleaseDate.Equal(stored.ReleaseDate) {
+			t.Errorf("movie = %+v, want the fields left out kept", *m)
+		}
+		if len(m.Genres) != 1 || m.Genres[0].ID != 18 {
+			t.Errorf("genres = %v, want Drama kept", m.Genres)
+		}
+		if !slices.Equal(m.ManualFields, []string{"title"}) {
+			t.Errorf("manual_fields = %v, want only title", m.ManualFields)
+		}
+	}
+
+	resp = send(t, app, http.MethodPut, target, map[string]any{"genre_ids": []uint{18, 53}}, &got)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("genres PUT status = %d, want 200", resp.StatusCode)
+	}
+	if len(got.Genres) != 2 || !slices.Equal(got.ManualFields, []string{"title", "genres"}) {
+		t.Errorf("after setting genres: genres = %v, manual_fields = %v", got.Genres, got.ManualFields)
+	}
+
+	resp = send(t, app, http.MethodPut, target, map[string]any{"genre_ids": []uint{99}}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown genre PUT status = %d, want 400", resp.StatusCode)
+	}
+}
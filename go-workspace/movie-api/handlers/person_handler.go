@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+)
+
+// GetMovieCredits handles GET /api/movies/:id/credits
+func (h *MovieHandler) GetMovieCredits(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid movie ID",
+		})
+	}
+
+	credits, err := h.movieCredits(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Movie not found",
+			})
+		}
+		return serverErrorResponse(c, err, "Failed to fetch movie credits")
+	}
+
+	return c.JSON(credits)
+}
+
+// GetPerson handles GET /api/people/:id
+func (h *MovieHandler) GetPerson(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid person ID",
+		})
+	}
+
+	person, err := h.repo.GetPersonByID(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Person not found",
+			})
+		}
+		return serverErrorResponse(c, err, "Failed to fetch person")
+	}
+
+	return c.JSON(person)
+}
+
+// GetPersonMovies handles GET /api/people/:id/movies
+func (h *MovieHandler) GetPersonMovies(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid person ID",
+		})
+	}
+
+	credits, err := h.personCredits(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Person not found",
+			})
+		}
+		return serverErrorResponse(c, err, "Failed to fetch person's movies")
+	}
+
+	return c.JSON(credits)
+}
+
+// movieCredits fetches the cast and crew of a movie, failing with
+// repository.ErrNotFound if the movie doesn't exist
+func (h *MovieHandler) movieCredits(ctx context.Context, movieID uint) (*models.MovieCredits, error) {
+	if _, err := h.repo.GetMovieByID(ctx, movieID); err != nil {
+		return nil, err
+	}
+	return h.repo.GetMovieCredits(ctx, movieID)
+}
+
+// personCredits fetches the movies a person has credits on, failing with
+// repository.ErrNotFound if the person doesn't exist
+func (h *MovieHandler) personCredits(ctx context.Context, personID uint) ([]models.Credit, error) {
+	if _, err := h.repo.GetPersonByID(ctx, personID); err != nil {
+		return nil, err
+	}
+	return h.repo.GetPersonCredits(ctx, personID)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/service"
+)
+
+// Limits of GET /api/sync/jobs
+const (
+	defaultJobsLimit = 20
+	maxJobsLimit     = 100
+)
+
+// SyncMovies handles POST /api/movies/sync?source=. It queues a background
+// sync and answers 202 with the job to poll. The other query parameters are
+// those of the source's list, as in ?source=discover&year=1994&genres=18
+func (h *MovieHandler) SyncMovies(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	source := c.Query("source", service.SourcePopular)
+	params := url.Values{}
+	for name, value := range c.Queries() {
+		if name != "source" {
+			params.Set(name, value)
+		}
+	}
+	if len(params) > 0 {
+		source += "?" + params.Encode()
+	}
+
+	job, err := h.jobs.Start(ctx, source)
+	if errors.Is(err, service.ErrUnknownSource) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return startedJobResponse(c, job, err)
+}
+
+// startedJobResponse answers a request that queued a sync job: 202 with the
+// job, or 409 with the job already syncing the source
+func startedJobResponse(c *fiber.Ctx, job *models.SyncJob, err error) error {
+	if err != nil {
+		if errors.Is(err, repository.ErrSyncJobActive) && job != nil {
+			c.Location(fmt.Sprintf("/api/sync/jobs/%d", job.ID))
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A sync of this source is already in progress",
+				"job":   job,
+			})
+		}
+		return serverErrorResponse(c, err, "Failed to start sync")
+	}
+
+	c.Location(fmt.Sprintf("/api/sync/jobs/%d", job.ID))
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetSyncJobs handles GET /api/sync/jobs?limit=
+func (h *MovieHandler) GetSyncJobs(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	limit := c.QueryInt("limit", defaultJobsLimit)
+	if limit < 1 || limit > maxJobsLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Limit must be between 1 and %d", maxJobsLimit),
+		})
+	}
+
+	jobs, err := h.jobs.List(ctx, limit)
+	if err != nil {
+		return serverErrorResponse(c, err, "Failed to fetch sync jobs")
+	}
+
+	return c.JSON(jobs)
+}
+
+// GetSyncJob handles GET /api/sync/jobs/:id
+func (h *MovieHandler) GetSyncJob(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sync job ID",
+		})
+	}
+
+	job, err := h.jobs.Get(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Sync job not found",
+			})
+		}
+		return serverErrorResponse(c, err, "Failed to fetch sync job")
+	}
+
+	return c.JSON(job)
+}
+
+// GetSyncSchedules handles GET /api/sync/schedules
+func (h *MovieHandler) GetSyncSchedules(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	schedules, err := h.schedules.List(ctx)
+	if err != nil {
+		return serverErrorResponse(c, err, "Failed to fetch sync schedules")
+	}
+
+	return c.JSON(schedules)
+}
+
+// PauseSyncSchedule handles POST /api/sync/schedules/:name/pause
+func (h *MovieHandler) PauseSyncSchedule(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	schedule, err := h.schedules.Pause(ctx, c.Params("name"))
+	if err != nil {
+		return scheduleErrorResponse(c, err, "Failed to pause sync schedule")
+	}
+
+	return c.JSON(schedule)
+}
+
+// ResumeSyncSchedule handles POST /api/sync/schedules/:name/resume
+func (h *MovieHandler) ResumeSyncSchedule(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	schedule, err := h.schedules.Resume(ctx, c.Params("name"))
+	if err != nil {
+		return scheduleErrorResponse(c, err, "Failed to resume sync schedule")
+	}
+
+	return c.JSON(schedule)
+}
+
+// TriggerSyncSchedule handles POST /api/sync/schedules/:name/trigger. It
+// queues a sync of the schedule's source right away, even if paused
+func (h *MovieHandler) TriggerSyncSchedule(c *fiber.Ctx) error {
+	ctx, cancel := requestContext(c, h.requestTimeout)
+	defer cancel()
+
+	job, err := h.schedules.Trigger(ctx, c.Params("name"))
+	if errors.Is(err, service.ErrUnknownSchedule) {
+		return scheduleErrorResponse(c, err, "")
+	}
+	return startedJobResponse(c, job, err)
+}
+
+// scheduleErrorResponse answers 404 for unknown schedules and falls back to
+// serverErrorResponse otherwise
+func scheduleErrorResponse(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, service.ErrUnknownSchedule) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sync schedule not found",
+		})
+	}
+	return serverErrorResponse(c, err, message)
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rohankarmacharya/movie-lib/models"
+)
+
+// waitForJob polls the job at target until it finishes
+func waitForJob(t *testing.T, app *fiber.App, target string) models.SyncJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var job models.SyncJob
+		if resp := send(t, app, http.MethodGet, target, nil, &job); resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s = %d, want 200", target, resp.StatusCode)
+		}
+		if !job.State.Active() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d still %s", job.ID, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncEndpoints(t *testing.T) {
+	app, _, fake := newApp(t)
+	get := func(target string, out any) *http.Response {
+		return send(t, app, http.MethodGet, target, nil, out)
+	}
+
+	// Slow TMDB down so the first job is still running for the second request
+	fake.SetLatency(50 * time.Millisecond)
+	var started models.SyncJob
+	resp := send(t, app, http.MethodPost, "/api/movies/sync", nil, &started)
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusAccepted || started.ID == 0 || location == "" {
+		t.Fatalf("POST /api/movies/sync = %d, %+v, Location %q; want 202 with the job", resp.StatusCode, started, location)
+	}
+	resp = send(t, app, http.MethodPost, "/api/movies/sync?source=popular", nil, nil)
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("Location") != location {
+		t.Errorf("second sync = %d, Location %q; want 409 pointing at %s", resp.StatusCode, resp.Header.Get("Location"), location)
+	}
+	fake.SetLatency(0)
+
+	job := waitForJob(t, app, location)
+	if job.State != models.SyncJobSucceeded || job.Report == nil || job.Report.Created == 0 {
+		t.Fatalf("finished job = %+v, want it succeeded with movies created", job)
+	}
+
+	var page moviePage
+	if resp := get("/api/movies?limit=100", &page); resp.StatusCode != http.StatusOK || len(page.Data) != job.Report.Created {
+		t.Errorf("GET /api/movies = %d with %d movies, want the %d synced", resp.StatusCode, len(page.Data), job.Report.Created)
+	}
+
+	var jobs []models.SyncJob
+	if resp := get("/api/sync/jobs", &jobs); resp.StatusCode != http.StatusOK || len(jobs) != 1 || jobs[0].ID != started.ID {
+		t.Errorf("GET /api/sync/jobs = %d, %+v; want the one job", resp.StatusCode, jobs)
+	}
+	for target, want := range map[string]int{
+		"/api/sync/jobs?limit=0": http.StatusBadRequest,
+		"/api/sync/jobs/999":     http.StatusNotFound,
+		"/api/sync/jobs/abc":     http.StatusBadRequest,
+	} {
+		if resp := get(target, nil); resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", target, resp.StatusCode, want)
+		}
+	}
+	if resp := send(t, app, http.MethodPost, "/api/movies/sync?source=nope", nil, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("sync of an unknown source = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rohankarmacharya/movie-lib/client"
+)
+
+// tmdbErrorResponse translates a TMDB client error into the matching HTTP response
+func tmdbErrorResponse(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Movie not found on TMDB",
+		})
+	case errors.Is(err, client.ErrRateLimited):
+		if retryAfter := client.RetryAfter(err); retryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "TMDB rate limit exceeded, try again later",
+		})
+	case errors.Is(err, client.ErrUpstreamUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "TMDB is currently unavailable",
+		})
+	case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrDecode):
+		// Our credentials or TMDB's response are at fault, not the caller's request
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	return serverErrorResponse(c, err, message)
+}
@@ -1,40 +1,184 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"movie-api/handlers"
 	"movie-api/routes"
 
+	"github.com/rohankarmacharya/movie-lib/client"
 	"github.com/rohankarmacharya/movie-lib/config"
+	"github.com/rohankarmacharya/movie-lib/migrate"
 	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/service"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// shutdownTimeout bounds how long a stopping server waits for requests and
+// sync jobs to wind down
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Load configuration: defaults, config file, environment, flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize database
-	config.ConnectDB() // Make sure this sets up the DB connection
+	if _, err := config.ConnectDB(cfg.DB); err != nil {
+		log.Fatal(err)
+	}
 	db, err := config.DB.DB()
 	if err != nil {
 		log.Fatalf("Failed to get database instance: %v", err)
 	}
 	defer db.Close()
 
-	// Auto migrate models
-	if err := config.DB.AutoMigrate(&models.Movie{}); err != nil {
+	// `movie-api migrate ...` manages the schema and exits
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(args[1:])
+		return
+	}
+
+	// Serving talks to TMDB, unlike the migrate command
+	if err := cfg.TMDB.ValidateCredentials(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	// Apply pending migrations; the migration lock keeps concurrently
+	// starting instances from migrating at the same time
+	if err := migrate.New(config.DB, migrate.All).Up(); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Wire the handlers to their dependencies
+	repo := repository.NewGormRepository(config.DB)
+	tmdb := newTMDBClient(cfg.TMDB)
+	mergePolicy := models.MergePolicy(cfg.Sync.MergePolicy)
+	jobs := service.NewSyncJobs(repo, service.NewSyncService(repo, tmdb, service.WithMergePolicy(mergePolicy)),
+		service.WithJobTimeout(cfg.Server.SyncTimeout),
+	)
+	scheduler, err := newScheduler(cfg, repo, jobs)
+	if err != nil {
+		log.Fatalf("Failed to set up sync schedules: %v", err)
+	}
+	h := handlers.NewMovieHandler(repo, tmdb, jobs, scheduler,
+		handlers.WithRequestTimeout(cfg.Server.RequestTimeout),
+		handlers.WithMergePolicy(mergePolicy),
+	)
+
+	// Jobs whose heartbeat stopped were cut off when their process stopped
+	if n, err := jobs.RecoverInterrupted(context.Background()); err != nil {
+		log.Fatalf("Failed to recover interrupted sync jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted sync jobs as failed", n)
+	}
+	jobs.WatchInterrupted()
+	if cfg.Sync.Scheduler {
+		scheduler.Start()
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		JSONEncoder: json.Marshal,
-		JSONDecoder: json.Unmarshal,
+		JSONEncoder:  json.Marshal,
+		JSONDecoder:  json.Unmarshal,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	})
 
 	// Setup routes
-	routes.MovieRoutes(app)
+	routes.MovieRoutes(app, h)
+
+	// Stop accepting requests and cancel running sync jobs on SIGINT/SIGTERM
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.Printf("Failed to shut down server: %v", err)
+		}
+	}()
 
 	// Start server
-	log.Fatal(app.Listen(":3000"))
+	if err := app.Listen(cfg.Server.Addr); err != nil {
+		log.Fatal(err)
+	}
+
+	scheduler.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := jobs.Shutdown(ctx); err != nil {
+		log.Printf("Failed to stop sync jobs: %v", err)
+	}
+}
+
+// newScheduler builds the sync scheduler described by the configuration.
+// Instances sharing a Postgres database elect a leader per schedule; a
+// SQLite database has a single instance
+func newScheduler(cfg *config.Config, repo repository.Repository, jobs *service.SyncJobs) (*service.Scheduler, error) {
+	var elector service.Elector = service.LocalElector{}
+	if cfg.DB.Driver == "postgres" {
+		locks, err := repository.NewAdvisoryLockElector(config.DB)
+		if err != nil {
+			return nil, err
+		}
+		elector = locks
+	}
+
+	schedules := make([]service.Schedule, 0, len(cfg.Sync.Schedules))
+	for _, sched := range cfg.Sync.Schedules {
+		jitter := sched.Jitter
+		if jitter == 0 {
+			jitter = cfg.Sync.Jitter
+		}
+		schedules = append(schedules, service.Schedule{
+			Name:   sched.Name,
+			Source: sched.Source,
+			Cron:   sched.Cron,
+			Jitter: jitter,
+		})
+	}
+
+	return service.NewScheduler(repo, jobs, elector, schedules)
+}
+
+// newTMDBClient builds the TMDB client described by the configuration
+func newTMDBClient(cfg config.TMDBConfig) *client.TMDBClient {
+	retry := client.DefaultRetryPolicy
+	retry.MaxRetries = cfg.MaxRetries
+
+	var cache client.Cache
+	if cfg.CacheSize > 0 {
+		cache = client.NewLRUCache(cfg.CacheSize)
+	}
+
+	return client.NewTMDBClient(
+		client.WithBaseURL(cfg.BaseURL),
+		client.WithAPIKey(cfg.APIKey),
+		client.WithBearerToken(cfg.BearerToken),
+		client.WithLanguage(cfg.Language),
+		client.WithRegion(cfg.Region),
+		client.WithUserAgent(cfg.UserAgent),
+		client.WithHTTPClient(&http.Client{Timeout: cfg.Timeout}),
+		client.WithRateLimit(cfg.RateLimit, cfg.Burst),
+		client.WithRetryPolicy(retry),
+		client.WithMaxPages(cfg.MaxPages),
+		client.WithCache(cache),
+	)
 }
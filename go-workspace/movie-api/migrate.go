@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/rohankarmacharya/movie-lib/config"
+	"github.com/rohankarmacharya/movie-lib/migrate"
+)
+
+// runMigrate handles `movie-api migrate up|down [steps]|status`
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: movie-api migrate up|down [steps]|status")
+	}
+
+	migrator := migrate.New(config.DB, migrate.All)
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		fmt.Println("Database migrated successfully!")
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		if err := migrator.Down(steps); err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", steps)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
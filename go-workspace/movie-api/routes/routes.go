@@ -6,8 +6,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// MovieRoutes configures all the movie-related routes
-func MovieRoutes(app *fiber.App) {
+// MovieRoutes configures all the movie-related routes on h
+func MovieRoutes(app *fiber.App, h *handlers.MovieHandler) {
 	// API v1 routes
 	api := app.Group("/api")
 	{
@@ -15,29 +15,77 @@ func MovieRoutes(app *fiber.App) {
 		movies := api.Group("/movies")
 		{
 			// Get all movies with filtering and pagination
-			movies.Get("/", handlers.GetMovies)
+			movies.Get("/", h.GetMovies)
+
+			// Suggest movies by title while typing
+			movies.Get("/autocomplete", h.AutocompleteMovies)
 
 			// Get single movie by ID
-			movies.Get("/:id", handlers.GetMovie)
+			movies.Get("/:id", h.GetMovie)
+
+			// Get cast and crew of a movie
+			movies.Get("/:id/credits", h.GetMovieCredits)
 
 			// Create new movie
-			movies.Post("/", handlers.CreateMovie)
+			movies.Post("/", h.CreateMovie)
 
-			// Sync movies from TMDB and store in DB
-			movies.Post("/sync", handlers.SyncMovies)
+			// Start a background sync of movies from TMDB
+			movies.Post("/sync", h.SyncMovies)
 
 			// Update existing movie
-			movies.Put("/:id", handlers.UpdateMovie)
+			movies.Put("/:id", h.UpdateMovie)
 
 			// Delete movie
-			movies.Delete("/:id", handlers.DeleteMovie)
+			movies.Delete("/:id", h.DeleteMovie)
 
 			// TMDB integration routes
 			tmdb := api.Group("/tmdb")
 			{
-				tmdb.Get("/movies/search", handlers.SearchTMDBMovies)
-				tmdb.Get("/movies/:id", handlers.GetTMDBMovieDetails)
+				tmdb.Get("/movies/search", h.SearchTMDBMovies)
+				tmdb.Get("/cache/stats", h.GetTMDBCacheStats)
+				tmdb.Get("/movies/:id<int>", h.GetTMDBMovieDetails)
+
+				// Movie lists: popular, now_playing, upcoming, top_rated,
+				// trending and discover
+				tmdb.Get("/movies/:list", h.GetTMDBMovieList)
 			}
 		}
+
+		// Genre catalogue
+		api.Get("/genres", h.GetGenres)
+
+		// Background sync jobs
+		syncJobs := api.Group("/sync/jobs")
+		{
+			// List recent sync jobs
+			syncJobs.Get("/", h.GetSyncJobs)
+
+			// Get the state, progress and report of a sync job
+			syncJobs.Get("/:id", h.GetSyncJob)
+		}
+
+		// Scheduled syncs
+		schedules := api.Group("/sync/schedules")
+		{
+			// List the schedules with their state and next run
+			schedules.Get("/", h.GetSyncSchedules)
+
+			// Pause or resume a schedule on every instance
+			schedules.Post("/:name/pause", h.PauseSyncSchedule)
+			schedules.Post("/:name/resume", h.ResumeSyncSchedule)
+
+			// Run a schedule now
+			schedules.Post("/:name/trigger", h.TriggerSyncSchedule)
+		}
+
+		// People routes
+		people := api.Group("/people")
+		{
+			// Get single person by ID
+			people.Get("/:id", h.GetPerson)
+
+			// Get the movies a person is credited on
+			people.Get("/:id/movies", h.GetPersonMovies)
+		}
 	}
 }
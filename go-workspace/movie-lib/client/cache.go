@@ -0,0 +1,189 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheCapacity is the number of responses kept by the default LRU cache
+const DefaultCacheCapacity = 1000
+
+// DefaultCacheTTL applies to endpoints without a TTL of their own
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultCacheTTLs are the per-endpoint TTLs used unless overridden with WithCacheTTL.
+// Endpoints are paths with numeric segments replaced by {id}
+var DefaultCacheTTLs = map[string]time.Duration{
+	"/movie/{id}":          time.Hour,
+	"/movie/{id}/credits":  time.Hour,
+	"/movie/popular":       10 * time.Minute,
+	"/movie/now_playing":   10 * time.Minute,
+	"/movie/upcoming":      10 * time.Minute,
+	"/movie/top_rated":     10 * time.Minute,
+	"/trending/movie/day":  10 * time.Minute,
+	"/trending/movie/week": 10 * time.Minute,
+	"/discover/movie":      10 * time.Minute,
+	"/search/movie":        10 * time.Minute,
+	"/genre/movie/list":    24 * time.Hour,
+	"/movie/changes":       time.Minute,
+}
+
+type revalidateKey struct{}
+
+// Revalidate returns a context under which the client checks cached
+// responses with TMDB before serving them, as when they have expired. Use it
+// when the data must be current, like when refreshing changed movies
+func Revalidate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidateKey{}, true)
+}
+
+// mustRevalidate reports whether ctx was made by Revalidate
+func mustRevalidate(ctx context.Context) bool {
+	revalidate, _ := ctx.Value(revalidateKey{}).(bool)
+	return revalidate
+}
+
+// CacheEntry is a cached TMDB response body
+type CacheEntry struct {
+	Body      []byte
+	ETag      string
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Fresh reports whether the entry can be served without asking TMDB
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// Cache stores TMDB responses keyed by endpoint and parameters. Implementations
+// must be safe for concurrent use; stale entries may be kept so they can be
+// revalidated with their ETag
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// CacheStats counts how TMDB lookups were served
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Revalidations uint64 `json:"revalidations"`
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry once full
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewLRUCache creates an in-memory cache holding at most capacity entries
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = DefaultCacheCapacity
+	}
+	return &LRUCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry stored under key and marks it as recently used
+func (c *LRUCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set stores entry under key, evicting the least recently used entry if full
+func (c *LRUCache) Set(key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruItem{key: key, entry: entry})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+// Delete removes the entry stored under key
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// Len returns the number of cached entries
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+var numericSegment = regexp.MustCompile(`/\d+`)
+
+// endpointOf turns a request path into its endpoint name, e.g. /movie/550 -> /movie/{id}
+func endpointOf(path string) string {
+	return numericSegment.ReplaceAllString(path, "/{id}")
+}
+
+// ttlFor returns how long a response from path may be cached, taking the
+// upstream Cache-Control header into account. A zero TTL means "store, but
+// revalidate before every use"; ok is false when the response must not be stored
+func (c *TMDBClient) ttlFor(path string, header http.Header) (ttl time.Duration, ok bool) {
+	ttl, found := c.cacheTTLs[endpointOf(path)]
+	if !found {
+		ttl = DefaultCacheTTL
+	}
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "private":
+			return 0, false
+		case directive == "no-cache":
+			ttl = 0
+		case strings.HasPrefix(directive, "max-age="):
+			if maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				if upstream := time.Duration(maxAge) * time.Second; upstream < ttl {
+					ttl = upstream
+				}
+			}
+		}
+	}
+
+	return ttl, ttl > 0 || header.Get("ETag") != ""
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sentinel errors for the ways a TMDB request can fail; match them with errors.Is
+var (
+	// ErrNotFound means TMDB has no resource with the requested ID
+	ErrNotFound = errors.New("tmdb: resource not found")
+	// ErrUnauthorized means TMDB rejected our API key or token
+	ErrUnauthorized = errors.New("tmdb: unauthorized")
+	// ErrRateLimited means TMDB kept answering 429 after all retries
+	ErrRateLimited = errors.New("tmdb: rate limited")
+	// ErrUpstreamUnavailable means TMDB could not be reached or answered with a 5xx
+	ErrUpstreamUnavailable = errors.New("tmdb: upstream unavailable")
+	// ErrDecode means TMDB answered 200 with a body that could not be decoded
+	ErrDecode = errors.New("tmdb: invalid response body")
+)
+
+// APIError is returned when TMDB answers with a non-200 status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// StatusMessage is TMDB's own error message from the body, if any
+	StatusMessage string
+	// RetryAfter is the delay TMDB asked for on 429/503 responses
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+	}
+
+	var tmdbErr struct {
+		StatusMessage string `json:"status_message"`
+	}
+	if json.Unmarshal(body, &tmdbErr) == nil {
+		apiErr.StatusMessage = tmdbErr.StatusMessage
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.StatusMessage != "" {
+		return fmt.Sprintf("API request failed with status: %s: %s", e.Status, e.StatusMessage)
+	}
+	return fmt.Sprintf("API request failed with status: %s", e.Status)
+}
+
+// Is maps the upstream status code onto the sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUpstreamUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// RequestError describes a TMDB request that failed, including how many
+// attempts were made before giving up
+type RequestError struct {
+	Path     string
+	Attempts int
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("tmdb request %s failed after %d attempt(s): %v", e.Path, e.Attempts, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns the delay TMDB asked for if err came from a 429 or 503 response
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+)
+
+// MovieList names a TMDB movie list
+type MovieList string
+
+// The movie lists TMDB serves
+const (
+	ListPopular    MovieList = "popular"
+	ListNowPlaying MovieList = "now_playing"
+	ListUpcoming   MovieList = "upcoming"
+	ListTopRated   MovieList = "top_rated"
+	ListTrending   MovieList = "trending"
+	ListDiscover   MovieList = "discover"
+)
+
+// MovieLists are all the lists, in the order they are documented
+var MovieLists = []MovieList{ListPopular, ListNowPlaying, ListUpcoming, ListTopRated, ListTrending, ListDiscover}
+
+// Trending windows
+const (
+	TrendingDay  = "day"
+	TrendingWeek = "week"
+)
+
+// DiscoverSortOptions are the sort_by values /discover/movie accepts
+var DiscoverSortOptions = []string{
+	"popularity.asc", "popularity.desc",
+	"primary_release_date.asc", "primary_release_date.desc",
+	"revenue.asc", "revenue.desc",
+	"title.asc", "title.desc",
+	"original_title.asc", "original_title.desc",
+	"vote_average.asc", "vote_average.desc",
+	"vote_count.asc", "vote_count.desc",
+}
+
+// ErrUnknownList is returned when parsing a list TMDB doesn't serve
+var ErrUnknownList = errors.New("unknown TMDB movie list")
+
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// ListQuery selects a TMDB movie list. Window only applies to trending; the
+// other fields are the filters of discover and are left out when zero
+type ListQuery struct {
+	List   MovieList
+	Window string
+
+	Year             int
+	Genres           []int // movies must have all of them
+	MinVoteCount     int
+	SortBy           string
+	OriginalLanguage string
+}
+
+// ParseListQuery builds the query of list from params, which use the names
+// window (trending), and year, genres, vote_count.gte, sort_by and
+// with_original_language (discover). genres is a comma-separated list.
+// Unknown and misplaced parameters are rejected
+func ParseListQuery(list string, params url.Values) (ListQuery, error) {
+	q := ListQuery{List: MovieList(list)}
+	if !slices.Contains(MovieLists, q.List) {
+		return q, fmt.Errorf("%w: %q", ErrUnknownList, list)
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		value := params.Get(name)
+		var err error
+		switch {
+		case name == "window" && q.List == ListTrending:
+			q.Window = value
+			if value != TrendingDay && value != TrendingWeek {
+				err = fmt.Errorf("window must be %s or %s", TrendingDay, TrendingWeek)
+			}
+		case name == "year" && q.List == ListDiscover:
+			q.Year, err = strconv.Atoi(value)
+			if err != nil || q.Year < 1800 || q.Year > 9999 {
+				err = fmt.Errorf("year must be a year, got %q", value)
+			}
+		case name == "genres" && q.List == ListDiscover:
+			for _, g := range strings.Split(value, ",") {
+				id, convErr := strconv.Atoi(strings.TrimSpace(g))
+				if convErr != nil || id < 1 {
+					err = fmt.Errorf("genres must be comma-separated genre IDs, got %q", value)
+					break
+				}
+				q.Genres = append(q.Genres, id)
+			}
+			slices.Sort(q.Genres)
+			q.Genres = slices.Compact(q.Genres)
+		case name == "vote_count.gte" && q.List == ListDiscover:
+			q.MinVoteCount, err = strconv.Atoi(value)
+			if err != nil || q.MinVoteCount < 0 {
+				err = fmt.Errorf("vote_count.gte must be a non-negative integer, got %q", value)
+			}
+		case name == "sort_by" && q.List == ListDiscover:
+			q.SortBy = value
+			if !slices.Contains(DiscoverSortOptions, value) {
+				err = fmt.Errorf("sort_by must be one of %s", strings.Join(DiscoverSortOptions, ", "))
+			}
+		case name == "with_original_language" && q.List == ListDiscover:
+			q.OriginalLanguage = value
+			if !languageCode.MatchString(value) {
+				err = fmt.Errorf("with_original_language must be an ISO 639-1 code, got %q", value)
+			}
+		default:
+			err = fmt.Errorf("parameter %q is not supported by the %s list", name, list)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if q.List == ListTrending && q.Window == "" {
+		q.Window = TrendingDay
+	}
+	return q, errors.Join(errs...)
+}
+
+// Params returns the query's parameters in the form ParseListQuery reads
+func (q ListQuery) Params() url.Values {
+	params := url.Values{}
+	if q.List == ListTrending && q.Window != "" && q.Window != TrendingDay {
+		params.Set("window", q.Window)
+	}
+	if q.Year != 0 {
+		params.Set("year", strconv.Itoa(q.Year))
+	}
+	if len(q.Genres) > 0 {
+		params.Set("genres", joinInts(q.Genres, ","))
+	}
+	if q.MinVoteCount != 0 {
+		params.Set("vote_count.gte", strconv.Itoa(q.MinVoteCount))
+	}
+	if q.SortBy != "" {
+		params.Set("sort_by", q.SortBy)
+	}
+	if q.OriginalLanguage != "" {
+		params.Set("with_original_language", q.OriginalLanguage)
+	}
+	return params
+}
+
+// String returns the list followed by its parameters in a fixed order, like
+// "discover?genres=18,80&year=1994". Equal queries give equal strings
+func (q ListQuery) String() string {
+	params := q.Params()
+	if len(params) == 0 {
+		return string(q.List)
+	}
+	// Parsed values need no escaping, and commas read better unescaped
+	pairs := make([]string, 0, len(params))
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		pairs = append(pairs, name+"="+params.Get(name))
+	}
+	return string(q.List) + "?" + strings.Join(pairs, "&")
+}
+
+// endpoint returns the TMDB path and parameters of the query
+func (q ListQuery) endpoint() (string, url.Values) {
+	params := url.Values{}
+	switch q.List {
+	case ListTrending:
+		window := q.Window
+		if window == "" {
+			window = TrendingDay
+		}
+		return "/trending/movie/" + window, params
+	case ListDiscover:
+		if q.Year != 0 {
+			params.Set("primary_release_year", strconv.Itoa(q.Year))
+		}
+		if len(q.Genres) > 0 {
+			params.Set("with_genres", joinInts(q.Genres, ","))
+		}
+		if q.MinVoteCount != 0 {
+			params.Set("vote_count.gte", strconv.Itoa(q.MinVoteCount))
+		}
+		if q.SortBy != "" {
+			params.Set("sort_by", q.SortBy)
+		}
+		if q.OriginalLanguage != "" {
+			params.Set("with_original_language", q.OriginalLanguage)
+		}
+		return "/discover/movie", params
+	default:
+		return "/movie/" + string(q.List), params
+	}
+}
+
+func joinInts(values []int, sep string) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, sep)
+}
+
+// FetchListPage gets one page of a movie list
+func (c *TMDBClient) FetchListPage(ctx context.Context, q ListQuery, page int) (*MoviePage, error) {
+	path, params := q.endpoint()
+	return c.getMoviePage(ctx, path, params, page)
+}
+
+// ListPager returns a pager over a movie list; every page is fetched with ctx
+func (c *TMDBClient) ListPager(ctx context.Context, q ListQuery) *MoviePager {
+	return newMoviePager(func(page int) (*MoviePage, error) {
+		return c.FetchListPage(ctx, q, page)
+	}, c.maxPages)
+}
+
+// FetchList gets the movies of a list, walking as many pages as the client's
+// page cap allows
+func (c *TMDBClient) FetchList(ctx context.Context, q ListQuery) ([]models.Movie, error) {
+	return c.ListPager(ctx, q).All()
+}
@@ -0,0 +1,85 @@
+package client
+
+import "github.com/rohankarmacharya/movie-lib/models"
+
+// MaxPage is the highest page number TMDB will serve for list endpoints
+const MaxPage = 500
+
+// DefaultMaxPages is the number of pages a pager walks when no cap is configured
+const DefaultMaxPages = 5
+
+// MoviePager walks the pages of a paged TMDB endpoint, stopping at the last
+// upstream page or after a configurable number of pages
+//
+//	pager := tmdb.PopularPager(ctx)
+//	for pager.Next() {
+//		page := pager.Page()
+//		...
+//	}
+//	if err := pager.Err(); err != nil { ... }
+type MoviePager struct {
+	fetch    func(page int) (*MoviePage, error)
+	next     int
+	maxPages int
+	fetched  int
+	page     *MoviePage
+	err      error
+	done     bool
+}
+
+func newMoviePager(fetch func(page int) (*MoviePage, error), maxPages int) *MoviePager {
+	if maxPages < 1 {
+		maxPages = DefaultMaxPages
+	}
+	return &MoviePager{fetch: fetch, next: 1, maxPages: maxPages}
+}
+
+// WithMaxPages overrides the page cap for this pager
+func (p *MoviePager) WithMaxPages(maxPages int) *MoviePager {
+	if maxPages > 0 {
+		p.maxPages = maxPages
+	}
+	return p
+}
+
+// Next fetches the next page, returning false when there are no more pages,
+// the cap is reached or an error occurred
+func (p *MoviePager) Next() bool {
+	if p.done || p.fetched >= p.maxPages || p.next > MaxPage {
+		return false
+	}
+
+	page, err := p.fetch(p.next)
+	if err != nil {
+		p.err = err
+		p.done = true
+		return false
+	}
+
+	p.page = page
+	p.fetched++
+	p.next++
+	if page.Page >= page.TotalPages || len(page.Results) == 0 {
+		p.done = true
+	}
+	return true
+}
+
+// Page returns the page fetched by the last call to Next
+func (p *MoviePager) Page() *MoviePage {
+	return p.page
+}
+
+// Err returns the error that stopped the pager, if any
+func (p *MoviePager) Err() error {
+	return p.err
+}
+
+// All walks the remaining pages and returns their results combined
+func (p *MoviePager) All() ([]models.Movie, error) {
+	var movies []models.Movie
+	for p.Next() {
+		movies = append(movies, p.page.Results...)
+	}
+	return movies, p.err
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default client-side rate limit, comfortably below TMDB's per-IP limit
+const (
+	DefaultRateLimit = 20.0
+	DefaultBurst     = 10
+)
+
+// rateLimiter is a token bucket shared by all requests of one client
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	tokens  float64
+	last    time.Time
+	blocked time.Time // no tokens are handed out before this time
+}
+
+// newRateLimiter creates a token bucket allowing rate requests per second with
+// the given burst; a non-positive rate disables limiting
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it
+func (l *rateLimiter) reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	var wait time.Duration
+	if l.blocked.After(now) {
+		wait = l.blocked.Sub(now)
+	}
+
+	l.tokens--
+	if l.tokens < 0 {
+		deficit := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		if deficit > wait {
+			wait = deficit
+		}
+	}
+	return wait
+}
+
+// Wait blocks until the caller may send a request or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return sleep(ctx, l.reserve())
+}
+
+// sleep pauses for d, returning early with ctx's error if ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// blockUntil stops handing out tokens until t, e.g. after TMDB sent Retry-After
+func (l *rateLimiter) blockUntil(t time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.blocked) {
+		l.blocked = t
+	}
+}
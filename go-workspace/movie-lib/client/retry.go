@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed TMDB requests are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; 0 disables retrying
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubled on every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff
+	MaxDelay time.Duration
+	// MaxRetryAfter is the longest Retry-After the client is willing to wait;
+	// longer waits fail the request immediately
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy is used by clients that don't configure their own
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:    3,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      10 * time.Second,
+	MaxRetryAfter: time.Minute,
+}
+
+// backoff returns the jittered delay before the given retry (1-based)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Equal jitter: half the delay is fixed, the other half random
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isRetryable reports whether a failed attempt is worth repeating
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Transport errors (connection refused, timeouts, resets)
+	return errors.Is(err, ErrUpstreamUnavailable)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -1,123 +1,556 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/rohankarmacharya/movie-lib/models"
 )
 
-// FetchMovieDetails gets detailed information about a specific movie
-func FetchMovieDetails(movieID string) (*models.Movie, error) {
-	apiKey := os.Getenv("TMDB_API_KEY")
-	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%s?api_key=%s", movieID, apiKey)
+// DefaultBaseURL is the TMDB API host used when no base URL is configured
+const DefaultBaseURL = "https://api.themoviedb.org"
+
+// TMDBClient talks to the TMDB v3 API
+type TMDBClient struct {
+	baseURL     string
+	apiKey      string
+	bearerToken string
+	userAgent   string
+	language    string
+	region      string
+	maxPages    int
+	httpClient  *http.Client
+	limiter     *rateLimiter
+	retry       RetryPolicy
+
+	cache              Cache
+	cacheTTLs          map[string]time.Duration
+	cacheHits          atomic.Uint64
+	cacheMisses        atomic.Uint64
+	cacheRevalidations atomic.Uint64
+}
+
+// Option configures a TMDBClient
+type Option func(*TMDBClient)
+
+// WithBaseURL points the client at a different API host, e.g. a local stand-in server
+func WithBaseURL(baseURL string) Option {
+	return func(c *TMDBClient) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithAPIKey authenticates requests with a v3 api_key query parameter
+func WithAPIKey(apiKey string) Option {
+	return func(c *TMDBClient) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithBearerToken authenticates requests with a v4 read access token
+func WithBearerToken(token string) Option {
+	return func(c *TMDBClient) {
+		c.bearerToken = token
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *TMDBClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *TMDBClient) {
+		c.userAgent = userAgent
+	}
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+// WithLanguage sets the language parameter (e.g. "en-US") sent with every request
+func WithLanguage(language string) Option {
+	return func(c *TMDBClient) {
+		c.language = language
+	}
+}
+
+// WithRegion sets the region parameter (ISO 3166-1 code) sent with every request
+func WithRegion(region string) Option {
+	return func(c *TMDBClient) {
+		c.region = region
+	}
+}
+
+// WithMaxPages caps how many pages the client's pagers walk by default
+func WithMaxPages(maxPages int) Option {
+	return func(c *TMDBClient) {
+		c.maxPages = maxPages
+	}
+}
+
+// WithRateLimit limits the client to rate requests per second with the given
+// burst; a non-positive rate disables client-side limiting
+func WithRateLimit(rate float64, burst int) Option {
+	return func(c *TMDBClient) {
+		c.limiter = newRateLimiter(rate, burst)
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *TMDBClient) {
+		c.retry = policy
+	}
+}
+
+// WithCache stores responses in cache; nil disables caching
+func WithCache(cache Cache) Option {
+	return func(c *TMDBClient) {
+		c.cache = cache
+	}
+}
+
+// WithCacheTTL sets how long responses from an endpoint (e.g. "/movie/{id}") are cached
+func WithCacheTTL(endpoint string, ttl time.Duration) Option {
+	return func(c *TMDBClient) {
+		c.cacheTTLs[endpoint] = ttl
+	}
+}
+
+// NewTMDBClient creates a TMDB client configured by the given options
+func NewTMDBClient(opts ...Option) *TMDBClient {
+	c := &TMDBClient{
+		baseURL:    DefaultBaseURL,
+		maxPages:   DefaultMaxPages,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		limiter:    newRateLimiter(DefaultRateLimit, DefaultBurst),
+		retry:      DefaultRetryPolicy,
+		cache:      NewLRUCache(DefaultCacheCapacity),
+		cacheTTLs:  make(map[string]time.Duration),
+	}
+	for endpoint, ttl := range DefaultCacheTTLs {
+		c.cacheTTLs[endpoint] = ttl
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewTMDBClientFromEnv creates a TMDB client using TMDB_API_KEY, TMDB_BEARER_TOKEN
+// and TMDB_BASE_URL from the environment, followed by any extra options
+func NewTMDBClientFromEnv(opts ...Option) *TMDBClient {
+	envOpts := []Option{
+		WithAPIKey(os.Getenv("TMDB_API_KEY")),
+		WithBearerToken(os.Getenv("TMDB_BEARER_TOKEN")),
+	}
+	if baseURL := os.Getenv("TMDB_BASE_URL"); baseURL != "" {
+		envOpts = append(envOpts, WithBaseURL(baseURL))
+	}
+	return NewTMDBClient(append(envOpts, opts...)...)
+}
+
+// get performs a GET request against the TMDB API and decodes the JSON body into out
+func (c *TMDBClient) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	body, err := c.fetch(ctx, path, params)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %s", resp.Status)
+	if err := json.Unmarshal(body, out); err != nil {
+		// Don't keep serving a body we can't decode
+		if c.cache != nil {
+			c.cache.Delete(cacheKey(path, c.query(params)))
+		}
+		return fmt.Errorf("%w: error decoding response from %s: %v", ErrDecode, path, err)
+	}
+
+	return nil
+}
+
+// fetch returns the response body for path, serving it from the cache while
+// fresh and revalidating stale entries with their ETag
+func (c *TMDBClient) fetch(ctx context.Context, path string, params url.Values) ([]byte, error) {
+	query := c.query(params)
+	if c.cache == nil {
+		resp, err := c.do(ctx, path, query, "")
+		if err != nil {
+			return nil, err
+		}
+		return resp.body, nil
+	}
+
+	key := cacheKey(path, query)
+	now := time.Now()
+	entry, found := c.cache.Get(key)
+	if found && entry.Fresh(now) && !mustRevalidate(ctx) {
+		c.cacheHits.Add(1)
+		return entry.Body, nil
+	}
+
+	etag := ""
+	if found {
+		etag = entry.ETag
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	resp, err := c.do(ctx, path, query, etag)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		c.cacheMisses.Add(1)
+		return nil, err
 	}
 
-	var movie models.Movie
-	if err := json.Unmarshal(body, &movie); err != nil {
-		return nil, fmt.Errorf("error decoding response: %v", err)
+	ttl, storable := c.ttlFor(path, resp.header)
+	if resp.notModified && found {
+		c.cacheRevalidations.Add(1)
+		refreshed := *entry
+		refreshed.ExpiresAt = now.Add(ttl)
+		c.cache.Set(key, &refreshed)
+		return entry.Body, nil
 	}
 
-	return &movie, nil
+	c.cacheMisses.Add(1)
+	if storable {
+		c.cache.Set(key, &CacheEntry{
+			Body:      resp.body,
+			ETag:      resp.header.Get("ETag"),
+			StoredAt:  now,
+			ExpiresAt: now.Add(ttl),
+		})
+	} else if found {
+		c.cache.Delete(key)
+	}
+
+	return resp.body, nil
+}
+
+// CacheStats returns the client's cache hit, miss and revalidation counters
+func (c *TMDBClient) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:          c.cacheHits.Load(),
+		Misses:        c.cacheMisses.Load(),
+		Revalidations: c.cacheRevalidations.Load(),
+	}
+}
+
+// response is the part of a TMDB response the client cares about
+type response struct {
+	body        []byte
+	header      http.Header
+	notModified bool
 }
 
-// FetchMovies gets a list of popular movies
-func FetchMovies() ([]models.Movie, error) {
-	apiKey := os.Getenv("TMDB_API_KEY")
-	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/popular?api_key=%s", apiKey)
+// do sends a GET request, waiting on the rate limiter before every attempt and
+// retrying transient failures according to the client's retry policy. It gives
+// up as soon as ctx is done
+func (c *TMDBClient) do(ctx context.Context, path string, query url.Values, etag string) (*response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, &RequestError{Path: path, Attempts: attempt - 1, Err: err}
+		}
 
-	resp, err := http.Get(url)
+		resp, err := c.attempt(ctx, path, query, etag)
+		if err == nil {
+			return resp, nil
+		}
+
+		if attempt > c.retry.MaxRetries || !isRetryable(err) || ctx.Err() != nil {
+			return nil, &RequestError{Path: path, Attempts: attempt, Err: err}
+		}
+
+		delay := c.retry.backoff(attempt)
+		if retryAfter := RetryAfter(err); retryAfter > 0 {
+			if c.retry.MaxRetryAfter > 0 && retryAfter > c.retry.MaxRetryAfter {
+				return nil, &RequestError{Path: path, Attempts: attempt, Err: err}
+			}
+			delay = retryAfter
+			c.limiter.blockUntil(time.Now().Add(retryAfter))
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, &RequestError{Path: path, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+// attempt sends a single request and returns a 200 response, or a 304 when
+// etag was given and still matches
+func (c *TMDBClient) attempt(ctx context.Context, path string, query url.Values, etag string) (*response, error) {
+	req, err := c.newRequest(ctx, path, query)
 	if err != nil {
 		return nil, err
 	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The caller gave up; TMDB isn't at fault
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: error making request: %v", ErrUpstreamUnavailable, err)
+	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading response: %v", ErrUpstreamUnavailable, err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return &response{header: resp.Header, notModified: true}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+
+	return &response{body: body, header: resp.Header}, nil
+}
+
+// query merges params with the client's credentials and default parameters
+func (c *TMDBClient) query(params url.Values) url.Values {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	if c.apiKey != "" && c.bearerToken == "" {
+		query.Set("api_key", c.apiKey)
+	}
+	if c.language != "" && query.Get("language") == "" {
+		query.Set("language", c.language)
+	}
+	if c.region != "" && query.Get("region") == "" {
+		query.Set("region", c.region)
+	}
+	return query
+}
+
+// cacheKey identifies a request by endpoint and parameters, leaving out credentials
+func cacheKey(path string, query url.Values) string {
+	keyQuery := url.Values{}
+	for k, v := range query {
+		if k != "api_key" {
+			keyQuery[k] = v
+		}
+	}
+	return path + "?" + keyQuery.Encode()
+}
+
+// newRequest builds a GET request for path with the given query
+func (c *TMDBClient) newRequest(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	reqURL := c.baseURL + "/3" + path
+	if encoded := query.Encode(); encoded != "" {
+		reqURL += "?" + encoded
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	return req, nil
+}
+
+// FetchMovieDetails gets detailed information about a specific movie
+func (c *TMDBClient) FetchMovieDetails(ctx context.Context, movieID string) (*models.Movie, error) {
+	details, err := c.FetchTMDBMovieDetails(ctx, movieID)
 	if err != nil {
 		return nil, err
 	}
 
-	var tmdbResp TMDBResponse
-	if err := json.Unmarshal(body, &tmdbResp); err != nil {
+	movie := details.ToMovie()
+	return &movie, nil
+}
+
+// FetchTMDBMovieDetails gets the raw TMDB details for a specific movie
+func (c *TMDBClient) FetchTMDBMovieDetails(ctx context.Context, movieID string) (*TMDBMovieDetails, error) {
+	var details TMDBMovieDetails
+	if err := c.get(ctx, "/movie/"+url.PathEscape(movieID), nil, &details); err != nil {
 		return nil, err
 	}
 
-	var movies []models.Movie
-	for _, m := range tmdbResp.Results {
-		releaseDate, _ := time.Parse("2006-01-02", m.ReleaseDate)
-		movies = append(movies, models.Movie{
-			ExternalID:  fmt.Sprint(m.ID),
-			Title:       m.Title,
-			Description: m.Overview,
-			ReleaseDate: releaseDate,
-			Rating:      float64(m.VoteAverage),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		})
+	return &details, nil
+}
+
+// FetchMovieCredits gets the cast and crew of a specific movie
+func (c *TMDBClient) FetchMovieCredits(ctx context.Context, movieID string) (*TMDBCredits, error) {
+	var credits TMDBCredits
+	if err := c.get(ctx, "/movie/"+url.PathEscape(movieID)+"/credits", nil, &credits); err != nil {
+		return nil, err
 	}
 
-	return movies, nil
+	return &credits, nil
 }
 
-// SearchMovies searches for movies by query using the TMDB API
-func SearchMovies(query string) ([]models.Movie, error) {
-	apiKey := os.Getenv("TMDB_API_KEY")
-	url := fmt.Sprintf("https://api.themoviedb.org/3/search/movie?api_key=%s&query=%s", apiKey, url.QueryEscape(query))
+// FetchGenres gets TMDB's movie genre catalogue
+func (c *TMDBClient) FetchGenres(ctx context.Context) ([]models.Genre, error) {
+	var list TMDBGenreList
+	if err := c.get(ctx, "/genre/movie/list", nil, &list); err != nil {
+		return nil, err
+	}
+
+	genres := make([]models.Genre, 0, len(list.Genres))
+	for _, g := range list.Genres {
+		genres = append(genres, models.Genre{ID: uint(g.ID), Name: g.Name})
+	}
+	return genres, nil
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+// FetchMovies gets the first page of popular movies
+func (c *TMDBClient) FetchMovies(ctx context.Context) ([]models.Movie, error) {
+	page, err := c.FetchMoviesPage(ctx, 1)
 	if err != nil {
-		return nil, fmt.Errorf("error making search request: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %s", resp.Status)
+	return page.Results, nil
+}
+
+// FetchMoviesPage gets one page of popular movies
+func (c *TMDBClient) FetchMoviesPage(ctx context.Context, page int) (*MoviePage, error) {
+	return c.getMoviePage(ctx, "/movie/popular", nil, page)
+}
+
+// PopularPager returns a pager over the popular movies list; every page is
+// fetched with ctx
+func (c *TMDBClient) PopularPager(ctx context.Context) *MoviePager {
+	return newMoviePager(func(page int) (*MoviePage, error) {
+		return c.FetchMoviesPage(ctx, page)
+	}, c.maxPages)
+}
+
+// MaxChangesWindow is the longest period one query of TMDB's changes
+// endpoints may cover
+const MaxChangesWindow = 14 * 24 * time.Hour
+
+// FetchChangesPage gets one page of the IDs of movies changed on TMDB
+// between the dates of start and end, both inclusive
+func (c *TMDBClient) FetchChangesPage(ctx context.Context, start, end time.Time, page int) (*TMDBChangesResponse, error) {
+	if page < 1 || page > MaxPage {
+		return nil, fmt.Errorf("page must be between 1 and %d", MaxPage)
+	}
+
+	query := url.Values{}
+	query.Set("start_date", start.UTC().Format("2006-01-02"))
+	query.Set("end_date", end.UTC().Format("2006-01-02"))
+	query.Set("page", strconv.Itoa(page))
+
+	var changes TMDBChangesResponse
+	if err := c.get(ctx, "/movie/changes", query, &changes); err != nil {
+		return nil, err
 	}
+	return &changes, nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// FetchChangedMovieIDs gets the IDs of every movie changed on TMDB between
+// start and end, walking all pages. Periods longer than MaxChangesWindow are
+// split into several queries. TMDB only keeps dates, so the result may
+// include movies changed earlier on start's day
+func (c *TMDBClient) FetchChangedMovieIDs(ctx context.Context, start, end time.Time) ([]string, error) {
+	seen := make(map[int]bool)
+	ids := []string{}
+	for from := start; from.Before(end); {
+		to := from.Add(MaxChangesWindow)
+		if to.After(end) {
+			to = end
+		}
+		for page := 1; page <= MaxPage; page++ {
+			changes, err := c.FetchChangesPage(ctx, from, to, page)
+			if err != nil {
+				return nil, err
+			}
+			for _, change := range changes.Results {
+				if !seen[change.ID] {
+					seen[change.ID] = true
+					ids = append(ids, strconv.Itoa(change.ID))
+				}
+			}
+			if page >= changes.TotalPages {
+				break
+			}
+		}
+		from = to
+	}
+	return ids, nil
+}
+
+// SearchMovies searches for movies by query using the TMDB API, returning the first page of results
+func (c *TMDBClient) SearchMovies(ctx context.Context, query string) ([]models.Movie, error) {
+	page, err := c.SearchMoviesPage(ctx, query, 1)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return nil, err
 	}
 
+	return page.Results, nil
+}
+
+// SearchMoviesPage gets one page of search results for query
+func (c *TMDBClient) SearchMoviesPage(ctx context.Context, query string, page int) (*MoviePage, error) {
+	params := url.Values{}
+	params.Set("query", query)
+
+	return c.getMoviePage(ctx, "/search/movie", params, page)
+}
+
+// SearchPager returns a pager over the search results for query; every page
+// is fetched with ctx
+func (c *TMDBClient) SearchPager(ctx context.Context, query string) *MoviePager {
+	return newMoviePager(func(page int) (*MoviePage, error) {
+		return c.SearchMoviesPage(ctx, query, page)
+	}, c.maxPages)
+}
+
+// getMoviePage fetches one page of a paged movie list endpoint
+func (c *TMDBClient) getMoviePage(ctx context.Context, path string, params url.Values, page int) (*MoviePage, error) {
+	if page < 1 || page > MaxPage {
+		return nil, fmt.Errorf("page must be between 1 and %d", MaxPage)
+	}
+
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("page", strconv.Itoa(page))
+
 	var tmdbResp TMDBResponse
-	if err := json.Unmarshal(body, &tmdbResp); err != nil {
-		return nil, fmt.Errorf("error decoding search response: %v", err)
-	}
-
-	var movies []models.Movie
-	for _, m := range tmdbResp.Results {
-		releaseDate, _ := time.Parse("2006-01-02", m.ReleaseDate)
-		movies = append(movies, models.Movie{
-			ExternalID:  fmt.Sprint(m.ID),
-			Title:       m.Title,
-			Description: m.Overview,
-			ReleaseDate: releaseDate,
-			Rating:      float64(m.VoteAverage),
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		})
+	if err := c.get(ctx, path, query, &tmdbResp); err != nil {
+		return nil, err
 	}
 
-	return movies, nil
+	return &MoviePage{
+		Results:      toMovies(tmdbResp.Results),
+		Page:         tmdbResp.Page,
+		TotalPages:   tmdbResp.TotalPages,
+		TotalResults: tmdbResp.TotalResults,
+	}, nil
+}
+
+// toMovies converts TMDB list results into movie models
+func toMovies(results []TMDBMovie) []models.Movie {
+	movies := make([]models.Movie, 0, len(results))
+	for _, m := range results {
+		movie := m.ToMovie()
+		movie.CreatedAt = time.Now()
+		movie.UpdatedAt = time.Now()
+		movies = append(movies, movie)
+	}
+	return movies
 }
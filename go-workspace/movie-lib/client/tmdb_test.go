@@ -0,0 +1,229 @@
+package client_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/client"
+	"github.com/rohankarmacharya/movie-lib/client/tmdbfake"
+)
+
+// fastRetries retries like the default policy without the long waits
+var fastRetries = client.RetryPolicy{
+	MaxRetries:    3,
+	BaseDelay:     time.Millisecond,
+	MaxDelay:      5 * time.Millisecond,
+	MaxRetryAfter: 2 * time.Second,
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client(client.WithRetryPolicy(fastRetries))
+
+	fake.FailNext(2, http.StatusBadGateway)
+	movie, err := c.FetchMovieDetails(t.Context(), "550")
+	if err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+	if movie.Title != "Fight Club" {
+		t.Errorf("title = %q, want Fight Club", movie.Title)
+	}
+	if n := fake.RequestCount(); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client(client.WithRetryPolicy(fastRetries))
+
+	fake.FailNext(10, http.StatusServiceUnavailable)
+	_, err := c.FetchMovieDetails(t.Context(), "550")
+	if !errors.Is(err, client.ErrUpstreamUnavailable) {
+		t.Fatalf("err = %v, want ErrUpstreamUnavailable", err)
+	}
+	var reqErr *client.RequestError
+	if !errors.As(err, &reqErr) || reqErr.Attempts != 4 {
+		t.Errorf("err = %#v, want a RequestError after 4 attempts", err)
+	}
+	if n := fake.RequestCount(); n != 4 {
+		t.Errorf("sent %d requests, want 4", n)
+	}
+}
+
+func TestHonorsRetryAfter(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client(client.WithRetryPolicy(fastRetries), client.WithCache(nil))
+
+	fake.SetRetryAfter("1")
+	fake.FailNext(1, http.StatusTooManyRequests)
+	start := time.Now()
+	if _, err := c.FetchMovieDetails(t.Context(), "550"); err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s TMDB asked for", elapsed)
+	}
+
+	// Retry-After holds back the whole client, not just the request that got it
+	fake.FailNext(1, http.StatusTooManyRequests)
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.FetchMovieDetails(t.Context(), "550")
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start = time.Now()
+	if _, err := c.FetchMovieDetails(t.Context(), "680"); err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("concurrent request went out after %v, want it held back", elapsed)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client(client.WithRetryPolicy(fastRetries))
+
+	fake.SetRetryAfter("120")
+	fake.FailNext(1, http.StatusTooManyRequests)
+	_, err := c.FetchMovieDetails(t.Context(), "550")
+	if !errors.Is(err, client.ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if d := client.RetryAfter(err); d != 2*time.Minute {
+		t.Errorf("RetryAfter = %v, want 2m", d)
+	}
+	if n := fake.RequestCount(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client(client.WithRateLimit(20, 1), client.WithCache(nil))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := c.FetchMovieDetails(t.Context(), "550"); err != nil {
+			t.Fatalf("FetchMovieDetails: %v", err)
+		}
+	}
+	// The first request uses the burst, the other four wait 50ms each
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("5 requests at 20/s took %v, want at least 200ms", elapsed)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	fake.RequireAPIKey("secret")
+
+	_, err := fake.Client().FetchMovieDetails(t.Context(), "550")
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("without a key: err = %v, want ErrUnauthorized", err)
+	}
+	if n := fake.RequestCount(); n != 1 {
+		t.Errorf("sent %d requests for a 401, want no retries", n)
+	}
+
+	c := fake.Client(client.WithAPIKey("secret"))
+	if _, err := c.FetchMovieDetails(t.Context(), "1"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("unknown movie: err = %v, want ErrNotFound", err)
+	}
+
+	fake.MalformNext(1)
+	if _, err := c.FetchMovieDetails(t.Context(), "550"); !errors.Is(err, client.ErrDecode) {
+		t.Errorf("malformed body: err = %v, want ErrDecode", err)
+	}
+	// The undecodable body isn't cached
+	if movie, err := c.FetchMovieDetails(t.Context(), "550"); err != nil || movie.Title != "Fight Club" {
+		t.Errorf("after a malformed body: got %v, %v; want Fight Club", movie, err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	c := fake.Client()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.FetchMovieDetails(t.Context(), "550"); err != nil {
+			t.Fatalf("FetchMovieDetails: %v", err)
+		}
+	}
+	if n := fake.RequestCount(); n != 1 {
+		t.Errorf("sent %d requests, want the second served from cache", n)
+	}
+	if stats := c.CacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	// Revalidate skips the fresh entry, and a matching ETag keeps it
+	if _, err := c.FetchMovieDetails(client.Revalidate(t.Context()), "550"); err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+	if n := fake.RequestCount(); n != 2 {
+		t.Errorf("sent %d requests, want a revalidation", n)
+	}
+	if stats := c.CacheStats(); stats.Revalidations != 1 {
+		t.Errorf("stats = %+v, want 1 revalidation", stats)
+	}
+}
+
+func TestCacheRevalidatesStaleEntries(t *testing.T) {
+	fake := tmdbfake.NewServer()
+	defer fake.Close()
+	// A zero TTL stores responses but revalidates them before every use
+	c := fake.Client(client.WithCacheTTL("/movie/{id}", 0))
+
+	if _, err := c.FetchMovieDetails(t.Context(), "550"); err != nil {
+		t.Fatalf("FetchMovieDetails: %v", err)
+	}
+	movie, err := c.FetchMovieDetails(t.Context(), "550")
+	if err != nil || movie.Title != "Fight Club" {
+		t.Fatalf("revalidated: got %v, %v; want Fight Club", movie, err)
+	}
+	if stats := c.CacheStats(); stats.Revalidations != 1 || stats.Hits != 0 {
+		t.Errorf("stats = %+v, want 1 revalidation", stats)
+	}
+
+	m := tmdbfake.DefaultMovies[5]
+	m.Title = "Fight Club (Remastered)"
+	fake.AddMovie(m)
+	movie, err = c.FetchMovieDetails(t.Context(), "550")
+	if err != nil || movie.Title != m.Title {
+		t.Fatalf("after a change: got %v, %v; want %q", movie, err, m.Title)
+	}
+	if stats := c.CacheStats(); stats.Revalidations != 1 || stats.Misses != 2 {
+		t.Errorf("stats = %+v, want the changed body fetched", stats)
+	}
+}
+
+func TestParseListQueryErrorOrder(t *testing.T) {
+	params := url.Values{"year": {"1"}, "sort_by": {"bogus"}, "genres": {"x"}, "window": {"day"}}
+	want := `genres must be comma-separated genre IDs, got "x"
+sort_by must be one of ` + strings.Join(client.DiscoverSortOptions, ", ") + `
+parameter "window" is not supported by the discover list
+year must be a year, got "1"`
+	for i := 0; i < 10; i++ {
+		_, err := client.ParseListQuery("discover", params)
+		if err == nil || err.Error() != want {
+			t.Fatalf("err = %v, want\n%s", err, want)
+		}
+	}
+}
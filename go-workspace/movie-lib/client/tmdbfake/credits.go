@@ -0,0 +1,107 @@
+package tmdbfake
+
+import "fmt"
+
+// Person is a person fixture
+type Person struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	ProfilePath        string `json:"profile_path"`
+	KnownForDepartment string `json:"known_for_department"`
+}
+
+// CastMember is a cast credit fixture
+type CastMember struct {
+	Person
+	Character string `json:"character"`
+	CreditID  string `json:"credit_id"`
+	Order     int    `json:"order"`
+}
+
+// CrewMember is a crew credit fixture
+type CrewMember struct {
+	Person
+	Department string `json:"department"`
+	Job        string `json:"job"`
+	CreditID   string `json:"credit_id"`
+}
+
+// Credits is the cast and crew of a movie fixture
+type Credits struct {
+	ID   int          `json:"id"`
+	Cast []CastMember `json:"cast"`
+	Crew []CrewMember `json:"crew"`
+}
+
+func actor(id int, name string) Person {
+	return Person{ID: id, Name: name, KnownForDepartment: "Acting"}
+}
+
+func director(id int, name string) Person {
+	return Person{ID: id, Name: name, KnownForDepartment: "Directing"}
+}
+
+// newCredits builds a credits fixture, generating credit IDs from the movie and person IDs
+func newCredits(movieID int, cast []CastMember, crew []CrewMember) Credits {
+	for i := range cast {
+		cast[i].Order = i
+		cast[i].CreditID = fmt.Sprintf("fake-%d-%d-cast", movieID, cast[i].ID)
+	}
+	for i := range crew {
+		crew[i].CreditID = fmt.Sprintf("fake-%d-%d-%s", movieID, crew[i].ID, crew[i].Job)
+	}
+	return Credits{ID: movieID, Cast: cast, Crew: crew}
+}
+
+func directedBy(p Person) []CrewMember {
+	return []CrewMember{{Person: p, Department: "Directing", Job: "Director"}}
+}
+
+var (
+	alPacino      = actor(1158, "Al Pacino")
+	coppola       = director(1776, "Francis Ford Coppola")
+	morganFreeman = actor(192, "Morgan Freeman")
+)
+
+// DefaultCredits are the credits served for the DefaultMovies, keyed by movie ID
+var DefaultCredits = map[int]Credits{
+	238: newCredits(238, []CastMember{
+		{Person: actor(3084, "Marlon Brando"), Character: "Don Vito Corleone"},
+		{Person: alPacino, Character: "Michael Corleone"},
+		{Person: actor(3085, "James Caan"), Character: "Sonny Corleone"},
+	}, directedBy(coppola)),
+	240: newCredits(240, []CastMember{
+		{Person: alPacino, Character: "Don Michael Corleone"},
+		{Person: actor(380, "Robert De Niro"), Character: "Vito Corleone"},
+	}, directedBy(coppola)),
+	278: newCredits(278, []CastMember{
+		{Person: actor(504, "Tim Robbins"), Character: "Andy Dufresne"},
+		{Person: morganFreeman, Character: "Ellis Boyd 'Red' Redding"},
+	}, directedBy(director(4027, "Frank Darabont"))),
+	155: newCredits(155, []CastMember{
+		{Person: actor(3894, "Christian Bale"), Character: "Bruce Wayne"},
+		{Person: actor(1810, "Heath Ledger"), Character: "Joker"},
+		{Person: morganFreeman, Character: "Lucius Fox"},
+	}, directedBy(director(525, "Christopher Nolan"))),
+	194: newCredits(194, []CastMember{
+		{Person: actor(3274, "Audrey Tautou"), Character: "Amélie Poulain"},
+	}, directedBy(director(2419, "Jean-Pierre Jeunet"))),
+	550: newCredits(550, []CastMember{
+		{Person: actor(819, "Edward Norton"), Character: "The Narrator"},
+		{Person: actor(287, "Brad Pitt"), Character: "Tyler Durden"},
+	}, directedBy(director(7467, "David Fincher"))),
+	680: newCredits(680, []CastMember{
+		{Person: actor(8891, "John Travolta"), Character: "Vincent Vega"},
+		{Person: actor(2231, "Samuel L. Jackson"), Character: "Jules Winnfield"},
+	}, directedBy(director(138, "Quentin Tarantino"))),
+	13: newCredits(13, []CastMember{
+		{Person: actor(31, "Tom Hanks"), Character: "Forrest Gump"},
+	}, directedBy(director(24, "Robert Zemeckis"))),
+	603: newCredits(603, []CastMember{
+		{Person: actor(6384, "Keanu Reeves"), Character: "Thomas A. Anderson / Neo"},
+		{Person: actor(2975, "Laurence Fishburne"), Character: "Morpheus"},
+	}, directedBy(director(9340, "Lana Wachowski"))),
+	129: newCredits(129, []CastMember{
+		{Person: actor(19587, "Rumi Hiiragi"), Character: "Chihiro Ogino / Sen (voice)"},
+	}, directedBy(director(608, "Hayao Miyazaki"))),
+}
@@ -0,0 +1,60 @@
+package tmdbfake
+
+// Genre is a genre fixture
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Movie is a movie fixture served by the fake TMDB server
+type Movie struct {
+	ID               int     `json:"id"`
+	IMDbID           string  `json:"imdb_id"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Tagline          string  `json:"tagline"`
+	Overview         string  `json:"overview"`
+	Status           string  `json:"status"`
+	PosterPath       string  `json:"poster_path"`
+	BackdropPath     string  `json:"backdrop_path"`
+	ReleaseDate      string  `json:"release_date"`
+	Runtime          int     `json:"runtime"`
+	Budget           int64   `json:"budget"`
+	Revenue          int64   `json:"revenue"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
+	Popularity       float64 `json:"popularity"`
+	Genres           []Genre `json:"genres"`
+}
+
+// Genre fixtures, using TMDB's genre IDs
+var (
+	Action    = Genre{ID: 28, Name: "Action"}
+	Animation = Genre{ID: 16, Name: "Animation"}
+	Comedy    = Genre{ID: 35, Name: "Comedy"}
+	Crime     = Genre{ID: 80, Name: "Crime"}
+	Drama     = Genre{ID: 18, Name: "Drama"}
+	Family    = Genre{ID: 10751, Name: "Family"}
+	Fantasy   = Genre{ID: 14, Name: "Fantasy"}
+	Romance   = Genre{ID: 10749, Name: "Romance"}
+	SciFi     = Genre{ID: 878, Name: "Science Fiction"}
+	Thriller  = Genre{ID: 53, Name: "Thriller"}
+)
+
+// DefaultGenres is the genre catalogue a new server is seeded with
+var DefaultGenres = []Genre{Action, Animation, Comedy, Crime, Drama, Family, Fantasy, Romance, SciFi, Thriller}
+
+// DefaultMovies is the fixture set a new server is seeded with
+var DefaultMovies = []Movie{
+	{ID: 238, IMDbID: "tt0068646", Title: "The Godfather", OriginalTitle: "The Godfather", OriginalLanguage: "en", Tagline: "An offer you can't refuse.", Overview: "Spanning the years 1945 to 1955, a chronicle of the fictional Italian-American Corleone crime family.", Status: "Released", PosterPath: "/3bhkrj58Vtu7enYsRolD1fZdja1.jpg", BackdropPath: "/tmU7GeKVybMWFButWEGl2M4GeiP.jpg", ReleaseDate: "1972-03-14", Runtime: 175, Budget: 6000000, Revenue: 245066411, VoteAverage: 8.7, VoteCount: 20000, Popularity: 120.5, Genres: []Genre{Drama, Crime}},
+	{ID: 240, IMDbID: "tt0071562", Title: "The Godfather Part II", OriginalTitle: "The Godfather Part II", OriginalLanguage: "en", Tagline: "The rise and fall of the Corleone empire.", Overview: "In the continuing saga of the Corleone crime family, a young Vito Corleone grows up in Sicily and in 1910s New York.", Status: "Released", PosterPath: "/hek3koDUyRQk7FIhPXsa6mT2Zc3.jpg", BackdropPath: "/kGzFbGhp99zva6oZODW5atUtnqi.jpg", ReleaseDate: "1974-12-20", Runtime: 202, Budget: 13000000, Revenue: 102600000, VoteAverage: 8.6, VoteCount: 12000, Popularity: 75.2, Genres: []Genre{Drama, Crime}},
+	{ID: 278, IMDbID: "tt0111161", Title: "The Shawshank Redemption", OriginalTitle: "The Shawshank Redemption", OriginalLanguage: "en", Tagline: "Fear can hold you prisoner. Hope can set you free.", Overview: "Imprisoned in the 1940s for the double murder of his wife and her lover, upstanding banker Andy Dufresne begins a new life at the Shawshank prison.", Status: "Released", PosterPath: "/9cqNxx0GxF0bflZmeSMuL5tnGzr.jpg", BackdropPath: "/zfbjgQE1uSd9wiPTX4VzsLi0rGG.jpg", ReleaseDate: "1994-09-23", Runtime: 142, Budget: 25000000, Revenue: 28341469, VoteAverage: 8.7, VoteCount: 26000, Popularity: 140.1, Genres: []Genre{Drama, Crime}},
+	{ID: 155, IMDbID: "tt0468569", Title: "The Dark Knight", OriginalTitle: "The Dark Knight", OriginalLanguage: "en", Tagline: "Welcome to a world without rules.", Overview: "Batman raises the stakes in his war on crime with the help of Lt. Jim Gordon and District Attorney Harvey Dent.", Status: "Released", PosterPath: "/qJ2tW6WMUDux911r6m7haRef0WH.jpg", BackdropPath: "/dqK9Hag1054tghRQSqLSfrkvQnA.jpg", ReleaseDate: "2008-07-16", Runtime: 152, Budget: 185000000, Revenue: 1004558444, VoteAverage: 8.5, VoteCount: 32000, Popularity: 160.8, Genres: []Genre{Drama, Action, Crime, Thriller}},
+	{ID: 194, IMDbID: "tt0211915", Title: "Amélie", OriginalTitle: "Le Fabuleux Destin d'Amélie Poulain", OriginalLanguage: "fr", Tagline: "One person can change your life forever.", Overview: "At a tiny Parisian café, the adorable yet painfully shy Amélie accidentally discovers a gift for helping others.", Status: "Released", PosterPath: "/nSxDa3M9aMvGVLoItzWTepQ5h5d.jpg", BackdropPath: "/5fWwpTXYANuzvUtzrwPG1yRyCRk.jpg", ReleaseDate: "2001-04-25", Runtime: 122, Budget: 10000000, Revenue: 174000000, VoteAverage: 7.9, VoteCount: 11000, Popularity: 45.3, Genres: []Genre{Comedy, Romance}},
+	{ID: 550, IMDbID: "tt0137523", Title: "Fight Club", OriginalTitle: "Fight Club", OriginalLanguage: "en", Tagline: "Mischief. Mayhem. Soap.", Overview: "A ticking-time-bomb insomniac and a slippery soap salesman channel primal male aggression into a shocking new form of therapy.", Status: "Released", PosterPath: "/pB8BM7pdSp6B6Ih7QZ4DrQ3PmJK.jpg", BackdropPath: "/hZkgoQYus5vegHoetLkCJzb17zJ.jpg", ReleaseDate: "1999-10-15", Runtime: 139, Budget: 63000000, Revenue: 100853753, VoteAverage: 8.4, VoteCount: 29000, Popularity: 98.7, Genres: []Genre{Drama, Thriller}},
+	{ID: 680, IMDbID: "tt0110912", Title: "Pulp Fiction", OriginalTitle: "Pulp Fiction", OriginalLanguage: "en", Tagline: "Just because you are a character doesn't mean you have character.", Overview: "A burger-loving hit man, his philosophical partner, a drug-addled gangster's moll and a washed-up boxer converge in this sprawling crime caper.", Status: "Released", PosterPath: "/d5iIlFn5s0ImszYzBPb8JPIfbXD.jpg", BackdropPath: "/suaEOtk1N1sgg2MTM7oZd2cfVp3.jpg", ReleaseDate: "1994-09-10", Runtime: 154, Budget: 8500000, Revenue: 213928762, VoteAverage: 8.5, VoteCount: 27000, Popularity: 88.9, Genres: []Genre{Thriller, Crime}},
+	{ID: 13, IMDbID: "tt0109830", Title: "Forrest Gump", OriginalTitle: "Forrest Gump", OriginalLanguage: "en", Tagline: "The world will never be the same once you've seen it through the eyes of Forrest Gump.", Overview: "A man with a low IQ has accomplished great things in his life and been present during significant historic events.", Status: "Released", PosterPath: "/arw2vcBveWOVZr6pxd9XTd1TdQa.jpg", BackdropPath: "/qdIMHd4sEfJSckfVJfKQvisL02a.jpg", ReleaseDate: "1994-06-23", Runtime: 142, Budget: 55000000, Revenue: 677387716, VoteAverage: 8.5, VoteCount: 26000, Popularity: 92.4, Genres: []Genre{Comedy, Drama, Romance}},
+	{ID: 603, IMDbID: "tt0133093", Title: "The Matrix", OriginalTitle: "The Matrix", OriginalLanguage: "en", Tagline: "Welcome to the Real World.", Overview: "Set in the 22nd century, The Matrix tells the story of a computer hacker who joins a group of underground insurgents fighting the vast and powerful computers who now rule the earth.", Status: "Released", PosterPath: "/f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg", BackdropPath: "/fNG7i7RqMErkcqhohV2a6cV1Ehy.jpg", ReleaseDate: "1999-03-31", Runtime: 136, Budget: 63000000, Revenue: 463517383, VoteAverage: 8.2, VoteCount: 25000, Popularity: 110.0, Genres: []Genre{Action, SciFi}},
+	{ID: 129, IMDbID: "tt0245429", Title: "Spirited Away", OriginalTitle: "千と千尋の神隠し", OriginalLanguage: "ja", Tagline: "The tunnel led Chihiro to a mysterious town...", Overview: "A young girl, Chihiro, becomes trapped in a strange new world of spirits.", Status: "Released", PosterPath: "/39wmItIWsg5sZMyRUHLkWBcuVCM.jpg", BackdropPath: "/6oaL4DP75yABrd5EbC4H2zq5ghc.jpg", ReleaseDate: "2001-07-20", Runtime: 125, Budget: 19000000, Revenue: 274925095, VoteAverage: 8.5, VoteCount: 16000, Popularity: 80.6, Genres: []Genre{Animation, Family, Fantasy}},
+}
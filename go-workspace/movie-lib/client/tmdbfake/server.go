@@ -0,0 +1,601 @@
+// Package tmdbfake provides an in-memory stand-in for the TMDB API, for tests
+// and offline development.
+package tmdbfake
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/client"
+)
+
+// PageSize is the number of results returned per page by list endpoints
+const PageSize = 20
+
+// ChangesPageSize is the number of IDs returned per page by /movie/changes
+const ChangesPageSize = 100
+
+// Server is a fake TMDB API backed by in-memory fixtures
+type Server struct {
+	srv     *httptest.Server
+	handler http.Handler
+
+	mu         sync.Mutex
+	movies     map[int]Movie
+	credits    map[int]Credits
+	changed    map[int]time.Time
+	genres     []Genre
+	apiKey     string
+	latency    time.Duration
+	failures   []int
+	retryAfter string
+	malformed  int
+	requests   int
+}
+
+// New creates a fake TMDB API seeded with DefaultMovies without starting a
+// listener; serve it with http.ListenAndServe or use NewServer instead
+func New() *Server {
+	s := &Server{movies: make(map[int]Movie), credits: make(map[int]Credits), changed: make(map[int]time.Time)}
+	for _, m := range DefaultMovies {
+		s.movies[m.ID] = m
+	}
+	for id, c := range DefaultCredits {
+		s.credits[id] = c
+	}
+	s.genres = append(s.genres, DefaultGenres...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /3/movie/popular", s.handlePopular)
+	mux.HandleFunc("GET /3/movie/now_playing", s.handleNowPlaying)
+	mux.HandleFunc("GET /3/movie/upcoming", s.handleUpcoming)
+	mux.HandleFunc("GET /3/movie/top_rated", s.handleTopRated)
+	mux.HandleFunc("GET /3/trending/movie/{window}", s.handleTrending)
+	mux.HandleFunc("GET /3/discover/movie", s.handleDiscover)
+	mux.HandleFunc("GET /3/movie/changes", s.handleChanges)
+	mux.HandleFunc("GET /3/search/movie", s.handleSearch)
+	mux.HandleFunc("GET /3/movie/{id}", s.handleMovie)
+	mux.HandleFunc("GET /3/movie/{id}/credits", s.handleCredits)
+	mux.HandleFunc("GET /3/genre/movie/list", s.handleGenres)
+
+	s.handler = s.middleware(mux)
+	return s
+}
+
+// NewServer starts a fake TMDB server on a local httptest listener
+func NewServer() *Server {
+	s := New()
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// URL returns the base URL of a server started with NewServer, suitable for client.WithBaseURL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts down a server started with NewServer
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a TMDB client pointed at the server, followed by any extra options
+func (s *Server) Client(opts ...client.Option) *client.TMDBClient {
+	base := []client.Option{
+		client.WithBaseURL(s.URL()),
+		client.WithHTTPClient(s.srv.Client()),
+	}
+	return client.NewTMDBClient(append(base, opts...)...)
+}
+
+// AddMovie adds or replaces a movie fixture and reports it as changed now
+func (s *Server) AddMovie(m Movie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.movies[m.ID] = m
+	s.changed[m.ID] = time.Now()
+}
+
+// RemoveMovie deletes a movie fixture and reports it as changed now
+func (s *Server) RemoveMovie(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.movies, id)
+	s.changed[id] = time.Now()
+}
+
+// SetCredits adds or replaces the credits of a movie fixture and reports the
+// movie as changed now
+func (s *Server) SetCredits(movieID int, credits Credits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credits.ID = movieID
+	s.credits[movieID] = credits
+	s.changed[movieID] = time.Now()
+}
+
+// MarkChanged reports a movie as changed at the given time in /movie/changes
+func (s *Server) MarkChanged(id int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed[id] = at
+}
+
+// SetGenres replaces the genre catalogue
+func (s *Server) SetGenres(genres []Genre) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.genres = append([]Genre(nil), genres...)
+}
+
+// RequireAPIKey makes the server answer 401 unless requests carry the given
+// api_key parameter or bearer token
+func (s *Server) RequireAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// SetLatency delays every response by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next n requests answer with the given status code
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// SetRetryAfter sets the Retry-After header sent with injected 429 and 503 responses
+func (s *Server) SetRetryAfter(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retryAfter = value
+}
+
+// MalformNext makes the next n successful responses return invalid JSON
+func (s *Server) MalformNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.malformed += n
+}
+
+// RequestCount returns the number of requests the server has received
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// middleware applies latency, authentication and injected failures before
+// handing the request to the endpoint handlers
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		latency := s.latency
+		apiKey := s.apiKey
+		retryAfter := s.retryAfter
+		status := 0
+		if len(s.failures) > 0 {
+			status = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		malformed := false
+		if status == 0 && s.malformed > 0 {
+			s.malformed--
+			malformed = true
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if apiKey != "" && r.URL.Query().Get("api_key") != apiKey &&
+			r.Header.Get("Authorization") != "Bearer "+apiKey {
+			writeError(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+			return
+		}
+
+		if status != 0 {
+			if retryAfter != "" && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			writeError(w, status, 0, http.StatusText(status))
+			return
+		}
+
+		if malformed {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"page": 1, "results": [`))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handlePopular(w http.ResponseWriter, r *http.Request) {
+	movies := s.sortedMovies(func(a, b Movie) bool { return a.Popularity > b.Popularity })
+	writePage(w, r, movies)
+}
+
+// handleNowPlaying lists the movies already released, newest first
+func (s *Server) handleNowPlaying(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Format("2006-01-02")
+	var movies []Movie
+	for _, m := range s.sortedMovies(func(a, b Movie) bool { return a.ReleaseDate > b.ReleaseDate }) {
+		if m.ReleaseDate != "" && m.ReleaseDate <= today {
+			movies = append(movies, m)
+		}
+	}
+	writePage(w, r, movies)
+}
+
+// handleUpcoming lists the movies not released yet, soonest first
+func (s *Server) handleUpcoming(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Format("2006-01-02")
+	var movies []Movie
+	for _, m := range s.sortedMovies(func(a, b Movie) bool { return a.ReleaseDate < b.ReleaseDate }) {
+		if m.ReleaseDate > today {
+			movies = append(movies, m)
+		}
+	}
+	writePage(w, r, movies)
+}
+
+func (s *Server) handleTopRated(w http.ResponseWriter, r *http.Request) {
+	movies := s.sortedMovies(func(a, b Movie) bool { return a.VoteAverage > b.VoteAverage })
+	writePage(w, r, movies)
+}
+
+// handleTrending ranks movies by popularity for both windows
+func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
+	if window := r.PathValue("window"); window != "day" && window != "week" {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+	movies := s.sortedMovies(func(a, b Movie) bool { return a.Popularity > b.Popularity })
+	writePage(w, r, movies)
+}
+
+// discoverSorts are the orders handleDiscover supports, ascending
+var discoverSorts = map[string]func(a, b Movie) bool{
+	"popularity":           func(a, b Movie) bool { return a.Popularity < b.Popularity },
+	"primary_release_date": func(a, b Movie) bool { return a.ReleaseDate < b.ReleaseDate },
+	"revenue":              func(a, b Movie) bool { return a.Revenue < b.Revenue },
+	"title":                func(a, b Movie) bool { return a.Title < b.Title },
+	"original_title":       func(a, b Movie) bool { return a.OriginalTitle < b.OriginalTitle },
+	"vote_average":         func(a, b Movie) bool { return a.VoteAverage < b.VoteAverage },
+	"vote_count":           func(a, b Movie) bool { return a.VoteCount < b.VoteCount },
+}
+
+// handleDiscover filters movies by primary_release_year, with_genres (all
+// of them), vote_count.gte and with_original_language, ordered by sort_by
+func (s *Server) handleDiscover(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	sortBy := q.Get("sort_by")
+	if sortBy == "" {
+		sortBy = "popularity.desc"
+	}
+	key, dir, _ := strings.Cut(sortBy, ".")
+	less, ok := discoverSorts[key]
+	if !ok || (dir != "asc" && dir != "desc") {
+		writeError(w, http.StatusUnprocessableEntity, 22, "Invalid sort_by: "+sortBy)
+		return
+	}
+	if dir == "desc" {
+		asc := less
+		less = func(a, b Movie) bool { return asc(b, a) }
+	}
+
+	var genres []int
+	if v := q.Get("with_genres"); v != "" {
+		for _, g := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(g)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, 22, "Invalid with_genres: "+v)
+				return
+			}
+			genres = append(genres, id)
+		}
+	}
+	minVotes, _ := strconv.Atoi(q.Get("vote_count.gte"))
+	year, language := q.Get("primary_release_year"), q.Get("with_original_language")
+
+	var movies []Movie
+	for _, m := range s.sortedMovies(less) {
+		if (year != "" && !strings.HasPrefix(m.ReleaseDate, year+"-")) ||
+			m.VoteCount < minVotes ||
+			(language != "" && m.OriginalLanguage != language) ||
+			!hasGenres(m, genres) {
+			continue
+		}
+		movies = append(movies, m)
+	}
+	writePage(w, r, movies)
+}
+
+func hasGenres(m Movie, ids []int) bool {
+	for _, id := range ids {
+		found := false
+		for _, g := range m.Genres {
+			if g.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// handleChanges lists the movies changed between start_date and end_date,
+// both inclusive, defaulting to the last day like TMDB
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	start, end := now.AddDate(0, 0, -1), now
+	if v := r.URL.Query().Get("start_date"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, 22, "Invalid date format: "+v)
+			return
+		}
+		start = d
+	}
+	if v := r.URL.Query().Get("end_date"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, 22, "Invalid date format: "+v)
+			return
+		}
+		end = d
+	}
+	if end.Sub(start) > 14*24*time.Hour {
+		writeError(w, http.StatusUnprocessableEntity, 22, "The date range is limited to 14 days.")
+		return
+	}
+	startDay, endDay := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	s.mu.Lock()
+	ids := make([]int, 0, len(s.changed))
+	for id, at := range s.changed {
+		day := at.UTC().Format("2006-01-02")
+		if day >= startDay && day <= endDay {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+	sort.Ints(ids)
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	from := min((page-1)*ChangesPageSize, len(ids))
+	to := min(from+ChangesPageSize, len(ids))
+
+	results := make([]map[string]interface{}, 0, to-from)
+	for _, id := range ids[from:to] {
+		results = append(results, map[string]interface{}{"id": id, "adult": false})
+	}
+	writeCacheable(w, r, map[string]interface{}{
+		"page":          page,
+		"results":       results,
+		"total_pages":   (len(ids) + ChangesPageSize - 1) / ChangesPageSize,
+		"total_results": len(ids),
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("query")))
+	var matches []Movie
+	if query != "" {
+		for _, m := range s.sortedMovies(func(a, b Movie) bool { return a.Popularity > b.Popularity }) {
+			if strings.Contains(strings.ToLower(m.Title), query) {
+				matches = append(matches, m)
+			}
+		}
+	}
+	writePage(w, r, matches)
+}
+
+func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+
+	s.mu.Lock()
+	movie, ok := s.movies[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+
+	writeCacheable(w, r, movie)
+}
+
+func (s *Server) handleCredits(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+
+	s.mu.Lock()
+	_, ok := s.movies[id]
+	credits, hasCredits := s.credits[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+	if !hasCredits {
+		credits = Credits{ID: id, Cast: []CastMember{}, Crew: []CrewMember{}}
+	}
+
+	writeCacheable(w, r, credits)
+}
+
+func (s *Server) handleGenres(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	genres := append([]Genre{}, s.genres...)
+	s.mu.Unlock()
+
+	writeCacheable(w, r, map[string]interface{}{"genres": genres})
+}
+
+// sortedMovies returns a snapshot of the fixtures ordered by less, with ID as tie-breaker
+func (s *Server) sortedMovies(less func(a, b Movie) bool) []Movie {
+	s.mu.Lock()
+	movies := make([]Movie, 0, len(s.movies))
+	for _, m := range s.movies {
+		movies = append(movies, m)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(movies, func(i, j int) bool {
+		if less(movies[i], movies[j]) {
+			return true
+		}
+		if less(movies[j], movies[i]) {
+			return false
+		}
+		return movies[i].ID < movies[j].ID
+	})
+	return movies
+}
+
+// writePage writes the requested page of movies in TMDB's paged list format
+func writePage(w http.ResponseWriter, r *http.Request, movies []Movie) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	totalPages := (len(movies) + PageSize - 1) / PageSize
+	start := (page - 1) * PageSize
+	if start > len(movies) {
+		start = len(movies)
+	}
+	end := start + PageSize
+	if end > len(movies) {
+		end = len(movies)
+	}
+
+	results := make([]listItem, 0, end-start)
+	for _, m := range movies[start:end] {
+		results = append(results, toListItem(m))
+	}
+
+	writeCacheable(w, r, map[string]interface{}{
+		"page":          page,
+		"results":       results,
+		"total_pages":   totalPages,
+		"total_results": len(movies),
+	})
+}
+
+// listItem is the shape of a movie in TMDB's list endpoints
+type listItem struct {
+	ID               int     `json:"id"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Overview         string  `json:"overview"`
+	PosterPath       string  `json:"poster_path"`
+	BackdropPath     string  `json:"backdrop_path"`
+	ReleaseDate      string  `json:"release_date"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
+	Popularity       float64 `json:"popularity"`
+	GenreIDs         []int   `json:"genre_ids"`
+}
+
+func toListItem(m Movie) listItem {
+	genreIDs := make([]int, 0, len(m.Genres))
+	for _, g := range m.Genres {
+		genreIDs = append(genreIDs, g.ID)
+	}
+	return listItem{
+		ID:               m.ID,
+		Title:            m.Title,
+		OriginalTitle:    m.OriginalTitle,
+		OriginalLanguage: m.OriginalLanguage,
+		Overview:         m.Overview,
+		PosterPath:       m.PosterPath,
+		BackdropPath:     m.BackdropPath,
+		ReleaseDate:      m.ReleaseDate,
+		VoteAverage:      m.VoteAverage,
+		VoteCount:        m.VoteCount,
+		Popularity:       m.Popularity,
+		GenreIDs:         genreIDs,
+	}
+}
+
+// writeError writes an error body in TMDB's format
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"success":        false,
+		"status_code":    code,
+		"status_message": message,
+	})
+}
+
+// writeCacheable writes v with an ETag, answering 304 when the client already has it
+func writeCacheable(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, 0, err.Error())
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
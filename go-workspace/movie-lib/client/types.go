@@ -1,15 +1,209 @@
 package client
 
+import (
+	"strconv"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+)
+
 // TMDBMovie represents a movie from the TMDB API
 type TMDBMovie struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	Overview    string  `json:"overview"`
-	ReleaseDate string  `json:"release_date"`
-	VoteAverage float64 `json:"vote_average"`
+	ID               int     `json:"id"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Overview         string  `json:"overview"`
+	PosterPath       string  `json:"poster_path"`
+	BackdropPath     string  `json:"backdrop_path"`
+	ReleaseDate      string  `json:"release_date"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
+	Popularity       float64 `json:"popularity"`
+	GenreIDs         []int   `json:"genre_ids"`
 }
 
 // TMDBResponse represents the response from TMDB API
 type TMDBResponse struct {
-	Results []TMDBMovie `json:"results"`
+	Page         int         `json:"page"`
+	Results      []TMDBMovie `json:"results"`
+	TotalPages   int         `json:"total_pages"`
+	TotalResults int         `json:"total_results"`
+}
+
+// MoviePage is one page of movie results together with the upstream paging metadata
+type MoviePage struct {
+	Results      []models.Movie `json:"results"`
+	Page         int            `json:"page"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+}
+
+// TMDBChange represents an entry of TMDB's /movie/changes endpoint
+type TMDBChange struct {
+	ID    int   `json:"id"`
+	Adult *bool `json:"adult"`
+}
+
+// TMDBChangesResponse represents one page of TMDB's /movie/changes endpoint
+type TMDBChangesResponse struct {
+	Page         int          `json:"page"`
+	Results      []TMDBChange `json:"results"`
+	TotalPages   int          `json:"total_pages"`
+	TotalResults int          `json:"total_results"`
+}
+
+// TMDBGenre represents a genre embedded in TMDB responses
+type TMDBGenre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// TMDBGenreList represents the response from TMDB's /genre/movie/list endpoint
+type TMDBGenreList struct {
+	Genres []TMDBGenre `json:"genres"`
+}
+
+// TMDBMovieDetails represents the response from TMDB's /movie/{id} endpoint
+type TMDBMovieDetails struct {
+	ID               int         `json:"id"`
+	IMDbID           string      `json:"imdb_id"`
+	Title            string      `json:"title"`
+	OriginalTitle    string      `json:"original_title"`
+	OriginalLanguage string      `json:"original_language"`
+	Tagline          string      `json:"tagline"`
+	Overview         string      `json:"overview"`
+	Status           string      `json:"status"`
+	PosterPath       string      `json:"poster_path"`
+	BackdropPath     string      `json:"backdrop_path"`
+	ReleaseDate      string      `json:"release_date"`
+	Runtime          int         `json:"runtime"`
+	Budget           int64       `json:"budget"`
+	Revenue          int64       `json:"revenue"`
+	VoteAverage      float64     `json:"vote_average"`
+	VoteCount        int         `json:"vote_count"`
+	Popularity       float64     `json:"popularity"`
+	Genres           []TMDBGenre `json:"genres"`
+}
+
+// ToMovie maps the details onto a movie model ready to be persisted
+func (d TMDBMovieDetails) ToMovie() models.Movie {
+	releaseDate, _ := time.Parse("2006-01-02", d.ReleaseDate)
+
+	genres := make([]models.Genre, 0, len(d.Genres))
+	for _, g := range d.Genres {
+		genres = append(genres, models.Genre{ID: uint(g.ID), Name: g.Name})
+	}
+
+	return models.Movie{
+		ExternalID:       strconv.Itoa(d.ID),
+		IMDbID:           d.IMDbID,
+		Title:            d.Title,
+		OriginalTitle:    d.OriginalTitle,
+		OriginalLanguage: d.OriginalLanguage,
+		Tagline:          d.Tagline,
+		Description:      d.Overview,
+		Status:           d.Status,
+		PosterPath:       d.PosterPath,
+		BackdropPath:     d.BackdropPath,
+		ReleaseDate:      releaseDate,
+		Runtime:          d.Runtime,
+		Budget:           d.Budget,
+		Revenue:          d.Revenue,
+		Rating:           d.VoteAverage,
+		VoteCount:        d.VoteCount,
+		Popularity:       d.Popularity,
+		Genres:           genres,
+	}
+}
+
+// ToMovie maps a list result onto a movie model. List results only carry
+// genre IDs, so the genres have no names
+func (m TMDBMovie) ToMovie() models.Movie {
+	releaseDate, _ := time.Parse("2006-01-02", m.ReleaseDate)
+
+	genres := make([]models.Genre, 0, len(m.GenreIDs))
+	for _, id := range m.GenreIDs {
+		genres = append(genres, models.Genre{ID: uint(id)})
+	}
+
+	return models.Movie{
+		ExternalID:       strconv.Itoa(m.ID),
+		Title:            m.Title,
+		OriginalTitle:    m.OriginalTitle,
+		OriginalLanguage: m.OriginalLanguage,
+		Description:      m.Overview,
+		PosterPath:       m.PosterPath,
+		BackdropPath:     m.BackdropPath,
+		ReleaseDate:      releaseDate,
+		Rating:           m.VoteAverage,
+		VoteCount:        m.VoteCount,
+		Popularity:       m.Popularity,
+		Genres:           genres,
+	}
+}
+
+// TMDBCastMember represents a cast entry from TMDB's /movie/{id}/credits endpoint
+type TMDBCastMember struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	ProfilePath        string `json:"profile_path"`
+	KnownForDepartment string `json:"known_for_department"`
+	Character          string `json:"character"`
+	CreditID           string `json:"credit_id"`
+	Order              int    `json:"order"`
+}
+
+// TMDBCrewMember represents a crew entry from TMDB's /movie/{id}/credits endpoint
+type TMDBCrewMember struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name"`
+	ProfilePath        string `json:"profile_path"`
+	KnownForDepartment string `json:"known_for_department"`
+	Department         string `json:"department"`
+	Job                string `json:"job"`
+	CreditID           string `json:"credit_id"`
+}
+
+// TMDBCredits represents the response from TMDB's /movie/{id}/credits endpoint
+type TMDBCredits struct {
+	ID   int              `json:"id"`
+	Cast []TMDBCastMember `json:"cast"`
+	Crew []TMDBCrewMember `json:"crew"`
+}
+
+// ToCredits maps the cast and crew onto credit models with their people
+// attached; MovieID is left for the caller to set
+func (c TMDBCredits) ToCredits() []models.Credit {
+	credits := make([]models.Credit, 0, len(c.Cast)+len(c.Crew))
+	for _, m := range c.Cast {
+		credits = append(credits, models.Credit{
+			CreditID:  m.CreditID,
+			Role:      models.CreditRoleCast,
+			Character: m.Character,
+			Order:     m.Order,
+			Person: &models.Person{
+				ExternalID:         strconv.Itoa(m.ID),
+				Name:               m.Name,
+				ProfilePath:        m.ProfilePath,
+				KnownForDepartment: m.KnownForDepartment,
+			},
+		})
+	}
+	for i, m := range c.Crew {
+		credits = append(credits, models.Credit{
+			CreditID:   m.CreditID,
+			Role:       models.CreditRoleCrew,
+			Department: m.Department,
+			Job:        m.Job,
+			Order:      i,
+			Person: &models.Person{
+				ExternalID:         strconv.Itoa(m.ID),
+				Name:               m.Name,
+				ProfilePath:        m.ProfilePath,
+				KnownForDepartment: m.KnownForDepartment,
+			},
+		})
+	}
+	return credits
 }
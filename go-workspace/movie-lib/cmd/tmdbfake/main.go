@@ -0,0 +1,19 @@
+// Command tmdbfake serves the fake TMDB API for offline development.
+// Point the API at it with TMDB_BASE_URL=http://localhost:8089
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/rohankarmacharya/movie-lib/client/tmdbfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8089", "address to listen on")
+	flag.Parse()
+
+	log.Printf("Fake TMDB API listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, tmdbfake.New()))
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rohankarmacharya/movie-lib/cron"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete application configuration. Values are layered, each
+// overriding the previous: defaults, config file, environment, flags
+type Config struct {
+	DB     DBConfig     `yaml:"db" toml:"db"`
+	TMDB   TMDBConfig   `yaml:"tmdb" toml:"tmdb"`
+	Server ServerConfig `yaml:"server" toml:"server"`
+	Sync   SyncConfig   `yaml:"sync" toml:"sync"`
+}
+
+// DBConfig holds the database connection and pool settings. Driver is
+// "postgres" or "sqlite"; Path is only used by sqlite
+type DBConfig struct {
+	Driver          string        `yaml:"driver" toml:"driver"`
+	Path            string        `yaml:"path" toml:"path"`
+	Host            string        `yaml:"host" toml:"host"`
+	Port            int           `yaml:"port" toml:"port"`
+	User            string        `yaml:"user" toml:"user"`
+	Password        string        `yaml:"password" toml:"password"`
+	Name            string        `yaml:"name" toml:"name"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+}
+
+// TMDBConfig holds the TMDB client settings
+type TMDBConfig struct {
+	BaseURL     string        `yaml:"base_url" toml:"base_url"`
+	APIKey      string        `yaml:"api_key" toml:"api_key"`
+	BearerToken string        `yaml:"bearer_token" toml:"bearer_token"`
+	Language    string        `yaml:"language" toml:"language"`
+	Region      string        `yaml:"region" toml:"region"`
+	UserAgent   string        `yaml:"user_agent" toml:"user_agent"`
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`
+	RateLimit   float64       `yaml:"rate_limit" toml:"rate_limit"`
+	Burst       int           `yaml:"burst" toml:"burst"`
+	MaxRetries  int           `yaml:"max_retries" toml:"max_retries"`
+	MaxPages    int           `yaml:"max_pages" toml:"max_pages"`
+	CacheSize   int           `yaml:"cache_size" toml:"cache_size"`
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	Addr         string        `yaml:"addr" toml:"addr"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// RequestTimeout bounds the database and TMDB work of one API request,
+	// SyncTimeout that of each background TMDB sync job; 0 means no deadline
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout"`
+	SyncTimeout    time.Duration `yaml:"sync_timeout" toml:"sync_timeout"`
+}
+
+// SyncConfig holds the settings of TMDB syncs. Scheduler turns the
+// scheduler of this instance on or off; Jitter is the default for schedules
+// that don't set their own. Schedules can only be set in the config file.
+// MergePolicy decides whether syncs overwrite fields edited through the API
+type SyncConfig struct {
+	Scheduler   bool             `yaml:"scheduler" toml:"scheduler"`
+	Jitter      time.Duration    `yaml:"jitter" toml:"jitter"`
+	Schedules   []ScheduleConfig `yaml:"schedules" toml:"schedules"`
+	MergePolicy string           `yaml:"merge_policy" toml:"merge_policy"`
+}
+
+// ScheduleConfig is one scheduled sync: Source is synced whenever the cron
+// expression Cron fires, after a random delay of up to Jitter
+type ScheduleConfig struct {
+	Name   string        `yaml:"name" toml:"name"`
+	Source string        `yaml:"source" toml:"source"`
+	Cron   string        `yaml:"cron" toml:"cron"`
+	Jitter time.Duration `yaml:"jitter" toml:"jitter"`
+}
+
+// DefaultTMDBBaseURL is the TMDB API host used unless configured otherwise
+const DefaultTMDBBaseURL = "https://api.themoviedb.org"
+
+// Default returns the configuration used when nothing else is set
+func Default() Config {
+	return Config{
+		DB: DBConfig{
+			Driver:          "postgres",
+			Path:            "movies.db",
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		TMDB: TMDBConfig{
+			BaseURL:    DefaultTMDBBaseURL,
+			Language:   "en-US",
+			Timeout:    10 * time.Second,
+			RateLimit:  20,
+			Burst:      10,
+			MaxRetries: 3,
+			MaxPages:   5,
+			CacheSize:  1000,
+		},
+		Server: ServerConfig{
+			Addr:         ":3000",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			IdleTimeout:  2 * time.Minute,
+
+			RequestTimeout: 15 * time.Second,
+			SyncTimeout:    30 * time.Minute,
+		},
+		Sync: SyncConfig{
+			Scheduler:   true,
+			Jitter:      time.Minute,
+			MergePolicy: string(models.DefaultMergePolicy),
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and flag
+type setting struct {
+	name   string // flag name
+	env    string
+	usage  string
+	target interface{}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"db-driver", "DB_DRIVER", "database driver (postgres or sqlite)", &c.DB.Driver},
+		{"db-path", "DB_PATH", "SQLite database file", &c.DB.Path},
+		{"db-host", "DB_HOST", "database host", &c.DB.Host},
+		{"db-port", "DB_PORT", "database port", &c.DB.Port},
+		{"db-user", "DB_USER", "database user", &c.DB.User},
+		{"db-password", "DB_PASSWORD", "database password", &c.DB.Password},
+		{"db-name", "DB_NAME", "database name", &c.DB.Name},
+		{"db-sslmode", "DB_SSLMODE", "database SSL mode", &c.DB.SSLMode},
+		{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "maximum open database connections (0 = unlimited)", &c.DB.MaxOpenConns},
+		{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "maximum idle database connections", &c.DB.MaxIdleConns},
+		{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "maximum lifetime of a database connection", &c.DB.ConnMaxLifetime},
+		{"db-conn-max-idle-time", "DB_CONN_MAX_IDLE_TIME", "maximum idle time of a database connection", &c.DB.ConnMaxIdleTime},
+
+		{"tmdb-base-url", "TMDB_BASE_URL", "TMDB API base URL", &c.TMDB.BaseURL},
+		{"tmdb-api-key", "TMDB_API_KEY", "TMDB v3 API key", &c.TMDB.APIKey},
+		{"tmdb-bearer-token", "TMDB_BEARER_TOKEN", "TMDB v4 read access token", &c.TMDB.BearerToken},
+		{"tmdb-language", "TMDB_LANGUAGE", "language for TMDB results", &c.TMDB.Language},
+		{"tmdb-region", "TMDB_REGION", "region for TMDB results", &c.TMDB.Region},
+		{"tmdb-user-agent", "TMDB_USER_AGENT", "User-Agent sent to TMDB", &c.TMDB.UserAgent},
+		{"tmdb-timeout", "TMDB_TIMEOUT", "timeout of a single TMDB request", &c.TMDB.Timeout},
+		{"tmdb-rate-limit", "TMDB_RATE_LIMIT", "TMDB requests per second (0 = unlimited)", &c.TMDB.RateLimit},
+		{"tmdb-burst", "TMDB_BURST", "TMDB request burst size", &c.TMDB.Burst},
+		{"tmdb-max-retries", "TMDB_MAX_RETRIES", "retries of a failed TMDB request", &c.TMDB.MaxRetries},
+		{"tmdb-max-pages", "TMDB_MAX_PAGES", "pages walked when paging through TMDB lists", &c.TMDB.MaxPages},
+		{"tmdb-cache-size", "TMDB_CACHE_SIZE", "cached TMDB responses (0 = no cache)", &c.TMDB.CacheSize},
+
+		{"addr", "SERVER_ADDR", "address the HTTP server listens on", &c.Server.Addr},
+		{"read-timeout", "SERVER_READ_TIMEOUT", "HTTP read timeout", &c.Server.ReadTimeout},
+		{"write-timeout", "SERVER_WRITE_TIMEOUT", "HTTP write timeout", &c.Server.WriteTimeout},
+		{"idle-timeout", "SERVER_IDLE_TIMEOUT", "HTTP keep-alive idle timeout", &c.Server.IdleTimeout},
+		{"request-timeout", "SERVER_REQUEST_TIMEOUT", "deadline for the work of one API request (0 = none)", &c.Server.RequestTimeout},
+		{"sync-timeout", "SERVER_SYNC_TIMEOUT", "deadline for a background TMDB sync job (0 = none)", &c.Server.SyncTimeout},
+
+		{"sync-scheduler", "SYNC_SCHEDULER", "run the sync schedules on this instance", &c.Sync.Scheduler},
+		{"sync-jitter", "SYNC_JITTER", "default random delay of scheduled syncs", &c.Sync.Jitter},
+		{"sync-merge-policy", "SYNC_MERGE_POLICY", "whether syncs overwrite edited fields (upstream-wins, local-wins or lock-field)", &c.Sync.MergePolicy},
+	}
+}
+
+// Load builds the configuration from defaults, the config file, the
+// environment and the command-line flags in args. The config file is taken
+// from -config or CONFIG_FILE. It returns the arguments left after the flags.
+// All problems found are reported together in the returned error
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are recorded first and applied last so they take precedence
+	fs := flag.NewFlagSet("movie-api", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: movie-api [flags] [migrate up|down [steps]|status]")
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.name] = fs.String(s.name, "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := setValue(s.target, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if value, ok := flagValues[f.Name]; ok {
+			if err := setValue(settingTarget(settings, f.Name), *value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+			}
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return &cfg, fs.Args(), nil
+}
+
+// loadFile overlays the values of a YAML (.yaml, .yml) or TOML (.toml) file
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file type %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks every value and reports all problems at once. TMDB
+// credentials are left to TMDBConfig.ValidateCredentials, as only the
+// commands that talk to TMDB need them
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch c.DB.Driver {
+	case "postgres":
+		check(c.DB.Host != "", "db.host is required")
+		check(c.DB.Port > 0 && c.DB.Port <= 65535, "db.port must be between 1 and 65535, got %d", c.DB.Port)
+		check(c.DB.User != "", "db.user is required")
+		check(c.DB.Name != "", "db.name is required")
+		switch c.DB.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "db.sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full, got %q", c.DB.SSLMode)
+		}
+	case "sqlite":
+		check(c.DB.Path != "", "db.path is required")
+	default:
+		check(false, "db.driver must be postgres or sqlite, got %q", c.DB.Driver)
+	}
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns must not be negative")
+	check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns,
+		"db.max_idle_conns (%d) must not exceed db.max_open_conns (%d)", c.DB.MaxIdleConns, c.DB.MaxOpenConns)
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+	check(c.DB.ConnMaxIdleTime >= 0, "db.conn_max_idle_time must not be negative")
+
+	if u, err := url.Parse(c.TMDB.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		check(false, "tmdb.base_url must be an absolute URL, got %q", c.TMDB.BaseURL)
+	}
+	check(c.TMDB.Timeout > 0, "tmdb.timeout must be positive")
+	check(c.TMDB.RateLimit >= 0, "tmdb.rate_limit must not be negative")
+	check(c.TMDB.RateLimit == 0 || c.TMDB.Burst >= 1, "tmdb.burst must be at least 1")
+	check(c.TMDB.MaxRetries >= 0, "tmdb.max_retries must not be negative")
+	check(c.TMDB.MaxPages >= 1, "tmdb.max_pages must be at least 1")
+	check(c.TMDB.CacheSize >= 0, "tmdb.cache_size must not be negative")
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.RequestTimeout >= 0, "server.request_timeout must not be negative")
+	check(c.Server.SyncTimeout >= 0, "server.sync_timeout must not be negative")
+
+	check(c.Sync.Jitter >= 0, "sync.jitter must not be negative")
+	check(models.MergePolicy(c.Sync.MergePolicy).Valid(), "sync.merge_policy must be upstream-wins, local-wins or lock-field, got %q", c.Sync.MergePolicy)
+	names := make(map[string]bool, len(c.Sync.Schedules))
+	for i, sched := range c.Sync.Schedules {
+		check(sched.Name != "", "sync.schedules[%d].name is required", i)
+		check(sched.Name == "" || !names[sched.Name], "sync.schedules[%d].name %q is used twice", i, sched.Name)
+		names[sched.Name] = true
+		check(sched.Source != "", "sync.schedules[%d].source is required", i)
+		if _, err := cron.Parse(sched.Cron); err != nil {
+			check(false, "sync.schedules[%d].cron: %v", i, err)
+		}
+		check(sched.Jitter >= 0, "sync.schedules[%d].jitter must not be negative", i)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateCredentials reports a missing API key or bearer token. Stand-in
+// servers don't need credentials, the real API does
+func (c TMDBConfig) ValidateCredentials() error {
+	if c.BaseURL == DefaultTMDBBaseURL && c.APIKey == "" && c.BearerToken == "" {
+		return errors.New("tmdb.api_key or tmdb.bearer_token is required")
+	}
+	return nil
+}
+
+// DSN returns the Postgres connection string
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		dsnValue(c.Host), dsnValue(c.User), dsnValue(c.Password), dsnValue(c.Name), c.Port, dsnValue(c.SSLMode),
+	)
+}
+
+// dsnValue quotes a value of a keyword/value connection string, escaping
+// backslashes and single quotes, so it may contain spaces
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func settingTarget(settings []setting, name string) interface{} {
+	for _, s := range settings {
+		if s.name == name {
+			return s.target
+		}
+	}
+	return nil
+}
+
+// setValue parses value into target according to its type
+func setValue(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*t = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*t = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*t = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/rohankarmacharya/movie-lib/config"
+)
+
+func TestValidateLeavesCredentialsOut(t *testing.T) {
+	cfg := config.Default()
+	cfg.DB.Driver = "sqlite"
+	cfg.DB.Path = "movies.db"
+	cfg.TMDB.APIKey, cfg.TMDB.BearerToken = "", ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate without TMDB credentials: %v", err)
+	}
+	if err := cfg.TMDB.ValidateCredentials(); err == nil {
+		t.Error("ValidateCredentials succeeded without credentials for the real API")
+	}
+
+	cfg.TMDB.BaseURL = "http://localhost:8081"
+	if err := cfg.TMDB.ValidateCredentials(); err != nil {
+		t.Errorf("ValidateCredentials for a stand-in server: %v", err)
+	}
+}
+
+func TestDSNQuotesValues(t *testing.T) {
+	db := config.DBConfig{Host: "db.local", Port: 5432, User: "movies", Password: `it's a \secret`, Name: "movies", SSLMode: "disable"}
+	want := `host='db.local' user='movies' password='it\'s a \\secret' dbname='movies' port=5432 sslmode='disable'`
+	if got := db.DSN(); got != want {
+		t.Errorf("DSN() = %s, want %s", got, want)
+	}
+}
@@ -2,12 +2,10 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
 
 	"github.com/joho/godotenv"
-	"github.com/rohankarmacharya/movie-lib/models"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -21,41 +19,33 @@ func init() {
 	}
 }
 
-func ConnectDB() {
-	dsn := fmt.Sprintf(
-		"host=localhost user=%s password=%s dbname=%s port=5432 sslmode=disable",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
-
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("failed to connect database.", err)
+// ConnectDB opens the database connection and applies the pool settings.
+// It never changes the schema; run the migrations in the migrate package for that
+func ConnectDB(cfg DBConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case "sqlite":
+		// SQLite leaves foreign keys off unless asked, which would skip the cascades
+		dialector = sqlite.Open(cfg.Path + "?_foreign_keys=on")
+	default:
+		dialector = postgres.Open(cfg.DSN())
 	}
 
-	fmt.Println("Database connected successfully!")
-
-	// Drop the table if it exists to avoid constraint issues
-	err = DB.Migrator().DropTable(&models.Movie{})
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
-		log.Println("Warning: Could not drop movies table:", err)
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	// Auto-migrate the model with the new schema
-	err = DB.AutoMigrate(&models.Movie{})
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Migration failed:", err)
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	// Ensure the unique index on external_id exists
-	if !DB.Migrator().HasIndex(&models.Movie{}, "external_id") {
-		err = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_movies_external_id ON movies(external_id)`).Error
-		if err != nil {
-			log.Fatal("Failed to create external_id index:", err)
-		}
-	}
-
-	fmt.Println("Database migrated successfully!")
+	DB = db
+	fmt.Println("Database connected successfully!")
+	return db, nil
 }
@@ -0,0 +1,86 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/cron"
+)
+
+func TestNext(t *testing.T) {
+	// A Sunday
+	from := time.Date(2026, 10, 18, 8, 37, 12, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2026-10-18 08:38"},
+		{"0 */6 * * *", "2026-10-18 12:00"},
+		{"@daily", "2026-10-19 00:00"},
+		{"@hourly", "2026-10-18 09:00"},
+		{"@weekly", "2026-10-25 00:00"},
+		{"30 2 * * mon-fri", "2026-10-19 02:30"},
+		{"0 0 1 * *", "2026-11-01 00:00"},
+		{"0 0 1 jan *", "2027-01-01 00:00"},
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+		// Both day fields restricted: either matches
+		{"0 0 13 * 5", "2026-10-23 00:00"},
+		{"15,45 9-17/4 * * *", "2026-10-18 09:15"},
+		{"5/20 * * * *", "2026-10-18 08:45"},
+		// 7 is Sunday too
+		{"0 0 * * 7", "2026-10-25 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := cron.Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("Parse(%q).Next = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, err := cron.Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2026, 10, 18, 8, 0, 0, 0, loc))
+	if want := time.Date(2026, 10, 19, 3, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := cron.Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next = %v, want the zero time", next)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"x * * * *",
+		"* * * foo *",
+	} {
+		if _, err := cron.Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// movieV1 is the movies table as first shipped
+type movieV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	ExternalID  string `gorm:"uniqueIndex:idx_external_id"`
+	Title       string `gorm:"not null"`
+	Description string
+	PosterPath  string
+	ReleaseDate time.Time
+	Rating      float64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (movieV1) TableName() string { return "movies" }
+
+var createMovies = Migration{
+	Version: 1,
+	Name:    "create_movies",
+	Up: func(tx *gorm.DB) error {
+		// AutoMigrate rather than CreateTable so databases created before
+		// versioned migrations are adopted as they are
+		return tx.AutoMigrate(&movieV1{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&movieV1{})
+	},
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// movieV2 adds the TMDB detail columns to movies
+type movieV2 struct {
+	ID               uint   `gorm:"primaryKey"`
+	ExternalID       string `gorm:"uniqueIndex:idx_external_id"`
+	IMDbID           string `gorm:"column:imdb_id"`
+	Title            string `gorm:"not null"`
+	OriginalTitle    string
+	OriginalLanguage string
+	Tagline          string
+	Description      string
+	Status           string
+	PosterPath       string
+	BackdropPath     string
+	ReleaseDate      time.Time
+	Runtime          int
+	Budget           int64
+	Revenue          int64
+	Rating           float64
+	VoteCount        int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (movieV2) TableName() string { return "movies" }
+
+type genreV2 struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name string `gorm:"not null"`
+}
+
+func (genreV2) TableName() string { return "genres" }
+
+type movieGenreV2 struct {
+	MovieID uint    `gorm:"primaryKey"`
+	GenreID uint    `gorm:"primaryKey;index"`
+	Movie   movieV2 `gorm:"constraint:OnDelete:CASCADE"`
+	Genre   genreV2 `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (movieGenreV2) TableName() string { return "movie_genres" }
+
+var movieDetailColumns = []string{
+	"IMDbID", "OriginalTitle", "OriginalLanguage", "Tagline", "Status",
+	"BackdropPath", "Runtime", "Budget", "Revenue", "VoteCount",
+}
+
+var addMovieDetailsAndGenres = Migration{
+	Version: 2,
+	Name:    "add_movie_details_and_genres",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&movieV2{}, &genreV2{}, &movieGenreV2{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(&movieGenreV2{}, &genreV2{}); err != nil {
+			return err
+		}
+		for _, column := range movieDetailColumns {
+			if tx.Migrator().HasColumn(&movieV2{}, column) {
+				if err := tx.Migrator().DropColumn(&movieV2{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
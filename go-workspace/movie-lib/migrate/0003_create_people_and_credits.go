@@ -0,0 +1,46 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type personV3 struct {
+	ID                 uint   `gorm:"primaryKey"`
+	ExternalID         string `gorm:"uniqueIndex:idx_people_external_id"`
+	Name               string `gorm:"not null"`
+	ProfilePath        string
+	KnownForDepartment string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (personV3) TableName() string { return "people" }
+
+type creditV3 struct {
+	ID         uint     `gorm:"primaryKey"`
+	CreditID   string   `gorm:"uniqueIndex:idx_credits_credit_id"`
+	MovieID    uint     `gorm:"not null;index"`
+	Movie      movieV2  `gorm:"constraint:OnDelete:CASCADE"`
+	PersonID   uint     `gorm:"not null;index"`
+	Person     personV3 `gorm:"constraint:OnDelete:CASCADE"`
+	Role       string   `gorm:"not null"`
+	Character  string
+	Department string
+	Job        string
+	Order      int `gorm:"column:credit_order"`
+}
+
+func (creditV3) TableName() string { return "credits" }
+
+var createPeopleAndCredits = Migration{
+	Version: 3,
+	Name:    "create_people_and_credits",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&personV3{}, &creditV3{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&creditV3{}, &personV3{})
+	},
+}
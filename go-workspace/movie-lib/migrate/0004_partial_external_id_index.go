@@ -0,0 +1,25 @@
+package migrate
+
+import (
+	"gorm.io/gorm"
+)
+
+// partialExternalIDIndex replaces the plain unique index on movies.external_id
+// with one that skips empty values, so any number of manually created movies
+// can exist without a TMDB ID
+var partialExternalIDIndex = Migration{
+	Version: 4,
+	Name:    "partial_external_id_index",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX IF EXISTS idx_external_id").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_movies_external_id ON movies (external_id) WHERE external_id <> ''").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX IF EXISTS idx_movies_external_id").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_external_id ON movies (external_id)").Error
+	},
+}
@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type syncJobV5 struct {
+	ID            uint   `gorm:"primaryKey"`
+	Source        string `gorm:"not null"`
+	State         string `gorm:"not null"`
+	ProgressStage string
+	ProgressDone  int
+	ProgressTotal int
+	Report        string    `gorm:"type:text"`
+	Error         string    `gorm:"type:text"`
+	CreatedAt     time.Time `gorm:"index"`
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+	UpdatedAt     time.Time
+}
+
+func (syncJobV5) TableName() string { return "sync_jobs" }
+
+var createSyncJobs = Migration{
+	Version: 5,
+	Name:    "create_sync_jobs",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&syncJobV5{}); err != nil {
+			return err
+		}
+		// At most one queued or running job per source, even across instances
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_jobs_active_source ON sync_jobs (source) WHERE state IN ('queued', 'running')").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&syncJobV5{})
+	},
+}
@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type syncScheduleV6 struct {
+	Name      string `gorm:"primaryKey"`
+	Paused    bool   `gorm:"not null;default:false"`
+	LastRunAt *time.Time
+	LastJobID *uint
+	LastError string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
+
+func (syncScheduleV6) TableName() string { return "sync_schedules" }
+
+var createSyncSchedules = Migration{
+	Version: 6,
+	Name:    "create_sync_schedules",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&syncScheduleV6{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&syncScheduleV6{})
+	},
+}
@@ -0,0 +1,26 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type syncWatermarkV7 struct {
+	Source      string    `gorm:"primaryKey"`
+	SyncedUntil time.Time `gorm:"not null"`
+	UpdatedAt   time.Time
+}
+
+func (syncWatermarkV7) TableName() string { return "sync_watermarks" }
+
+var createSyncWatermarks = Migration{
+	Version: 7,
+	Name:    "create_sync_watermarks",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&syncWatermarkV7{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&syncWatermarkV7{})
+	},
+}
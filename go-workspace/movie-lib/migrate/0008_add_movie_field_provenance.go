@@ -0,0 +1,39 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// movieV8 adds the provenance and locks of movie fields, stored as JSON
+// arrays of field names
+type movieV8 struct {
+	ManualFields string `gorm:"type:text"`
+	LockedFields string `gorm:"type:text"`
+}
+
+func (movieV8) TableName() string { return "movies" }
+
+var movieProvenanceColumns = []string{"ManualFields", "LockedFields"}
+
+var addMovieFieldProvenance = Migration{
+	Version: 8,
+	Name:    "add_movie_field_provenance",
+	Up: func(tx *gorm.DB) error {
+		for _, column := range movieProvenanceColumns {
+			if !tx.Migrator().HasColumn(&movieV8{}, column) {
+				if err := tx.Migrator().AddColumn(&movieV8{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, column := range movieProvenanceColumns {
+			if tx.Migrator().HasColumn(&movieV8{}, column) {
+				if err := tx.Migrator().DropColumn(&movieV8{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
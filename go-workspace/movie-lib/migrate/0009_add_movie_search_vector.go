@@ -0,0 +1,64 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// movieSearchVectorUp adds the full-text search document of movies on
+// Postgres: the title weighs most, then the description, then the cast
+// names. A trigger keeps it current as movies are written; saving credits
+// refreshes it for the cast
+var movieSearchVectorUp = []string{
+	`ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION movie_search_vector(movie_id bigint, title text, description text) RETURNS tsvector AS $$
+		SELECT setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce((
+				SELECT string_agg(p.name, ' ')
+				FROM credits c JOIN people p ON p.id = c.person_id
+				WHERE c.movie_id = $1 AND c.role = 'cast'
+			), '')), 'C')
+	$$ LANGUAGE sql STABLE`,
+	`CREATE OR REPLACE FUNCTION movies_search_vector_trigger() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector := movie_search_vector(NEW.id, NEW.title, NEW.description);
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS movies_search_vector ON movies`,
+	`CREATE TRIGGER movies_search_vector BEFORE INSERT OR UPDATE OF title, description ON movies
+		FOR EACH ROW EXECUTE FUNCTION movies_search_vector_trigger()`,
+	`UPDATE movies SET search_vector = movie_search_vector(id, title, description)`,
+	`CREATE INDEX IF NOT EXISTS idx_movies_search_vector ON movies USING GIN (search_vector)`,
+}
+
+var movieSearchVectorDown = []string{
+	`DROP INDEX IF EXISTS idx_movies_search_vector`,
+	`DROP TRIGGER IF EXISTS movies_search_vector ON movies`,
+	`DROP FUNCTION IF EXISTS movies_search_vector_trigger()`,
+	`DROP FUNCTION IF EXISTS movie_search_vector(bigint, text, text)`,
+	`ALTER TABLE movies DROP COLUMN IF EXISTS search_vector`,
+}
+
+// addMovieSearchVector only changes Postgres; SQLite searches by substring
+var addMovieSearchVector = Migration{
+	Version: 9,
+	Name:    "add_movie_search_vector",
+	Up: func(tx *gorm.DB) error {
+		return execPostgres(tx, movieSearchVectorUp)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execPostgres(tx, movieSearchVectorDown)
+	},
+}
+
+// execPostgres runs statements in order when tx is a Postgres database
+func execPostgres(tx *gorm.DB, statements []string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
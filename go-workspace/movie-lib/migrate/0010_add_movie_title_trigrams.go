@@ -0,0 +1,34 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// movieTitleTrigramsUp enables typo-tolerant title matching on Postgres.
+// fold_text lowercases and strips accents; unaccent itself is only STABLE,
+// so the wrapper names its dictionary to be usable in an index. The GiST
+// index serves both similarity filters and nearest-first ordering
+var movieTitleTrigramsUp = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE EXTENSION IF NOT EXISTS unaccent`,
+	`CREATE OR REPLACE FUNCTION fold_text(value text) RETURNS text AS $$
+		SELECT lower(public.unaccent('public.unaccent'::regdictionary, value))
+	$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT`,
+	`CREATE INDEX IF NOT EXISTS idx_movies_title_trgm ON movies USING GIST (fold_text(title) gist_trgm_ops)`,
+}
+
+// The extensions stay installed on the way down; other schemas may use them
+var movieTitleTrigramsDown = []string{
+	`DROP INDEX IF EXISTS idx_movies_title_trgm`,
+	`DROP FUNCTION IF EXISTS fold_text(text)`,
+}
+
+// addMovieTitleTrigrams only changes Postgres, like addMovieSearchVector
+var addMovieTitleTrigrams = Migration{
+	Version: 10,
+	Name:    "add_movie_title_trigrams",
+	Up: func(tx *gorm.DB) error {
+		return execPostgres(tx, movieTitleTrigramsUp)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execPostgres(tx, movieTitleTrigramsDown)
+	},
+}
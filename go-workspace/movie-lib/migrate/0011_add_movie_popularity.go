@@ -0,0 +1,27 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// movieV11 adds TMDB's popularity score, which the listing can sort by
+type movieV11 struct {
+	Popularity float64 `gorm:"not null;default:0"`
+}
+
+func (movieV11) TableName() string { return "movies" }
+
+var addMoviePopularity = Migration{
+	Version: 11,
+	Name:    "add_movie_popularity",
+	Up: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&movieV11{}, "Popularity") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&movieV11{}, "Popularity")
+	},
+	Down: func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&movieV11{}, "Popularity") {
+			return nil
+		}
+		return tx.Migrator().DropColumn(&movieV11{}, "Popularity")
+	},
+}
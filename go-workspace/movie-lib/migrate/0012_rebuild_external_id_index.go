@@ -0,0 +1,21 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// rebuildExternalIDIndex makes idx_movies_external_id partial on databases
+// where ConnectDB had already created it as a full unique index under that
+// name, which the IF NOT EXISTS of version 4 kept
+var rebuildExternalIDIndex = Migration{
+	Version: 12,
+	Name:    "rebuild_external_id_index",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX IF EXISTS idx_movies_external_id").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_movies_external_id ON movies (external_id) WHERE external_id <> ''").Error
+	},
+	// Version 11 expects the partial index too, so there is nothing to undo
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
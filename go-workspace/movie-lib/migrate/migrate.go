@@ -0,0 +1,185 @@
+// Package migrate applies the ordered, versioned schema migrations of the
+// movie database and records them in the schema_migrations table.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey identifies the Postgres advisory lock held while migrating
+const lockKey = 7411_2025
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName keeps the conventional table name regardless of naming strategy
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator for the given migrations, which are applied in version order
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	return m.withLock(func(db *gorm.DB) error {
+		applied, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			log.Printf("Applying migration %d_%s", mig.Version, mig.Name)
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := mig.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version:   mig.Version,
+					Name:      mig.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migrations, steps at a time
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return errors.New("steps must be at least 1")
+	}
+
+	return m.withLock(func(db *gorm.DB) error {
+		applied, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == nil {
+				return fmt.Errorf("migration %d_%s cannot be rolled back", mig.Version, mig.Name)
+			}
+
+			log.Printf("Rolling back migration %d_%s", mig.Version, mig.Name)
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := mig.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, mig.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			appliedAt := rec.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection while holding the migration lock,
+// so concurrently starting instances migrate one after the other
+func (m *Migrator) withLock(fn func(db *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		// Start every statement afresh while staying on the pinned connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		if conn.Dialector.Name() == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		}
+
+		if err := conn.AutoMigrate(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations table: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+// appliedVersions loads the applied migrations keyed by version
+func appliedVersions(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
@@ -0,0 +1,41 @@
+package migrate_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rohankarmacharya/movie-lib/migrate"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRebuildLegacyExternalIDIndex(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "movies.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := migrate.New(db, migrate.All[:11])
+	if err := m.Up(); err != nil {
+		t.Fatalf("migrate to 11: %v", err)
+	}
+	// What ConnectDB used to leave behind on databases already past version 4
+	for _, stmt := range []string{
+		"DROP INDEX idx_movies_external_id",
+		"CREATE UNIQUE INDEX idx_movies_external_id ON movies (external_id)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrate.New(db, migrate.All).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for _, title := range []string{"Home Movie", "Another Home Movie"} {
+		if err := db.Create(&models.Movie{Title: title}).Error; err != nil {
+			t.Errorf("create %q without external_id: %v", title, err)
+		}
+	}
+}
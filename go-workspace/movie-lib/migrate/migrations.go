@@ -0,0 +1,18 @@
+package migrate
+
+// All is every migration of the movie database, in version order. Migrations
+// are append-only: never edit one that has shipped, add a new one instead
+var All = []Migration{
+	createMovies,
+	addMovieDetailsAndGenres,
+	createPeopleAndCredits,
+	partialExternalIDIndex,
+	createSyncJobs,
+	createSyncSchedules,
+	createSyncWatermarks,
+	addMovieFieldProvenance,
+	addMovieSearchVector,
+	addMovieTitleTrigrams,
+	addMoviePopularity,
+	rebuildExternalIDIndex,
+}
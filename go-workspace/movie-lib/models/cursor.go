@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that wasn't issued for the
+// listing it is used with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a sorted movie listing: the movie at the edge of
+// a page, holding only its ID and the fields of the sort. Pages continue
+// after it, or end before it when Before is set
+type Cursor struct {
+	Pivot  Movie
+	Before bool
+}
+
+// cursorJSON is the encoded form of a cursor. Sort ties it to the order it
+// was issued for
+type cursorJSON struct {
+	Sort   string                     `json:"s"`
+	ID     uint                       `json:"id"`
+	Values map[string]json.RawMessage `json:"v"`
+	Before bool                       `json:"b,omitempty"`
+}
+
+// EncodeCursor returns an opaque cursor at movie in the order of keys.
+// Ordering by relevance needs movie.Rank
+func EncodeCursor(keys []SortKey, movie Movie, before bool) string {
+	c := cursorJSON{Sort: sortString(keys), ID: movie.ID, Values: make(map[string]json.RawMessage, len(keys)), Before: before}
+	for _, key := range keys {
+		// The values are strings, numbers and times, which always marshal
+		raw, _ := json.Marshal(sortValue(&movie, key.Field))
+		c.Values[key.Field] = raw
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor reads a cursor issued by EncodeCursor for the order of keys
+func DecodeCursor(keys []SortKey, cursor string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortString(keys) {
+		return nil, fmt.Errorf("%w: it was issued for sort=%s", ErrInvalidCursor, c.Sort)
+	}
+
+	decoded := &Cursor{Pivot: Movie{ID: c.ID, Rank: new(float64)}, Before: c.Before}
+	for _, key := range keys {
+		value, ok := c.Values[key.Field]
+		if !ok || json.Unmarshal(value, sortValue(&decoded.Pivot, key.Field)) != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return decoded, nil
+}
+
+// sortValue returns a pointer to the field of movie a sort field orders by
+func sortValue(movie *Movie, field string) interface{} {
+	switch field {
+	case "title":
+		return &movie.Title
+	case "release_date":
+		return &movie.ReleaseDate
+	case "rating":
+		return &movie.Rating
+	case "created_at":
+		return &movie.CreatedAt
+	case "updated_at":
+		return &movie.UpdatedAt
+	case "popularity":
+		return &movie.Popularity
+	case SortRelevance:
+		if movie.Rank == nil {
+			movie.Rank = new(float64)
+		}
+		return movie.Rank
+	}
+	return nil
+}
+
+// Value returns the pivot's value of a sort field
+func (c *Cursor) Value(field string) interface{} {
+	switch v := sortValue(&c.Pivot, field).(type) {
+	case *string:
+		return *v
+	case *float64:
+		return *v
+	case *time.Time:
+		return *v
+	}
+	return nil
+}
+
+// sortString formats keys the way Sort takes them
+func sortString(keys []SortKey) string {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key.Field
+		if key.Desc {
+			fields[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The facets the movie listing can count
+const (
+	// FacetGenre counts movies per genre ID, named
+	FacetGenre = "genre"
+	// FacetYear counts movies per release year
+	FacetYear = "year"
+	// FacetDecade counts movies per first year of their release decade
+	FacetDecade = "decade"
+	// FacetRating counts movies per rating band, the whole part of the
+	// rating: "8" counts ratings from 8 up to 9. Ratings of 10 fall in "9"
+	FacetRating = "rating"
+	// FacetLanguage counts movies per original language
+	FacetLanguage = "language"
+)
+
+// Facets are all the facets, in the order they are documented
+var Facets = []string{FacetGenre, FacetYear, FacetDecade, FacetRating, FacetLanguage}
+
+// FacetCount is how many movies of a listing have one value of a facet.
+// Movies without a value, like an unknown release date, aren't counted
+type FacetCount struct {
+	Value string `json:"value"`
+	// Name is the display name of a genre
+	Name  string `json:"name,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// ParseFacets parses a comma-separated list of Facets
+func ParseFacets(list string) ([]string, error) {
+	var facets []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(Facets, name) {
+			return nil, fmt.Errorf("unknown facet %q, use %s", name, strings.Join(Facets, ", "))
+		}
+		if !slices.Contains(facets, name) {
+			facets = append(facets, name)
+		}
+	}
+	return facets, nil
+}
@@ -0,0 +1,7 @@
+package models
+
+// Genre is a TMDB movie genre; the ID is TMDB's genre ID
+type Genre struct {
+	ID   uint   `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Name string `json:"name" gorm:"not null"`
+}
@@ -3,13 +3,42 @@ package models
 import "time"
 
 type Movie struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	ExternalID  string    `json:"external_id" gorm:"uniqueIndex:idx_external_id"`
-	Title       string    `json:"title" gorm:"not null"`
-	Description string    `json:"description,omitempty"`
-	PosterPath  string    `json:"poster_path,omitempty"`
-	ReleaseDate time.Time `json:"release_date" time_format:"2006-01-02"`
-	Rating      float64   `json:"rating,omitempty"`
-	CreatedAt   time.Time `json:"created_at,omitempty"`
-	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	ExternalID       string    `json:"external_id" gorm:"uniqueIndex:idx_movies_external_id,where:external_id <> ''"`
+	IMDbID           string    `json:"imdb_id,omitempty" gorm:"column:imdb_id"`
+	Title            string    `json:"title" gorm:"not null"`
+	OriginalTitle    string    `json:"original_title,omitempty"`
+	OriginalLanguage string    `json:"original_language,omitempty"`
+	Tagline          string    `json:"tagline,omitempty"`
+	Description      string    `json:"description,omitempty"`
+	Status           string    `json:"status,omitempty"`
+	PosterPath       string    `json:"poster_path,omitempty"`
+	BackdropPath     string    `json:"backdrop_path,omitempty"`
+	ReleaseDate      time.Time `json:"release_date" time_format:"2006-01-02"`
+	Runtime          int       `json:"runtime,omitempty"`
+	Budget           int64     `json:"budget,omitempty"`
+	Revenue          int64     `json:"revenue,omitempty"`
+	Rating           float64   `json:"rating,omitempty"`
+	VoteCount        int       `json:"vote_count,omitempty"`
+	// Popularity is TMDB's popularity score, refreshed by every sync
+	Popularity float64 `json:"popularity,omitempty" gorm:"not null;default:0"`
+	Genres     []Genre `json:"genres,omitempty" gorm:"many2many:movie_genres"`
+	// ManualFields are the fields last set through the API rather than by
+	// a sync; LockedFields are never overwritten by a sync. Both hold
+	// names from MovieFields
+	ManualFields []string `json:"manual_fields,omitempty" gorm:"serializer:json"`
+	LockedFields []string `json:"locked_fields,omitempty" gorm:"serializer:json"`
+	// Rank is how well the movie matches a search, higher is better. It is
+	// only set on search results
+	Rank      *float64  `json:"rank,omitempty" gorm:"-"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// MovieSuggestion is a movie proposed while typing a title
+type MovieSuggestion struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	// Year is the release year, left out when the release date is unknown
+	Year int `json:"year,omitempty"`
 }
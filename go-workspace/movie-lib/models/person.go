@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Credit roles
+const (
+	CreditRoleCast = "cast"
+	CreditRoleCrew = "crew"
+)
+
+// Person is an actor or crew member; ExternalID is the TMDB person ID
+type Person struct {
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	ExternalID         string    `json:"external_id" gorm:"uniqueIndex:idx_people_external_id"`
+	Name               string    `json:"name" gorm:"not null"`
+	ProfilePath        string    `json:"profile_path,omitempty"`
+	KnownForDepartment string    `json:"known_for_department,omitempty"`
+	CreatedAt          time.Time `json:"created_at,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at,omitempty"`
+}
+
+// Credit links a person to a movie, either as cast (with a character) or as
+// crew (with a department and job)
+type Credit struct {
+	ID         uint    `json:"id" gorm:"primaryKey"`
+	CreditID   string  `json:"credit_id" gorm:"uniqueIndex:idx_credits_credit_id"`
+	MovieID    uint    `json:"movie_id" gorm:"not null;index"`
+	Movie      *Movie  `json:"movie,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	PersonID   uint    `json:"person_id" gorm:"not null;index"`
+	Person     *Person `json:"person,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Role       string  `json:"role" gorm:"not null"`
+	Character  string  `json:"character,omitempty"`
+	Department string  `json:"department,omitempty"`
+	Job        string  `json:"job,omitempty"`
+	Order      int     `json:"order" gorm:"column:credit_order"`
+}
+
+// MovieCredits is the cast and crew of a movie
+type MovieCredits struct {
+	MovieID uint     `json:"movie_id"`
+	Cast    []Credit `json:"cast"`
+	Crew    []Credit `json:"crew"`
+}
@@ -0,0 +1,170 @@
+package models
+
+import "slices"
+
+// MergePolicy decides which fields of a stored movie a TMDB sync may
+// overwrite. Locked fields are never overwritten
+type MergePolicy string
+
+const (
+	// MergeUpstreamWins lets sync overwrite every field that isn't locked,
+	// including fields edited through the API
+	MergeUpstreamWins MergePolicy = "upstream-wins"
+	// MergeLocalWins keeps the fields edited through the API as well
+	MergeLocalWins MergePolicy = "local-wins"
+	// MergeLockField locks every field edited through the API, so it keeps
+	// its value until unlocked
+	MergeLockField MergePolicy = "lock-field"
+)
+
+// DefaultMergePolicy is the merge policy unless configured otherwise
+const DefaultMergePolicy = MergeLocalWins
+
+// MergePolicies are the valid merge policies
+var MergePolicies = []MergePolicy{MergeUpstreamWins, MergeLocalWins, MergeLockField}
+
+// Valid reports whether p is a known merge policy
+func (p MergePolicy) Valid() bool {
+	return slices.Contains(MergePolicies, p)
+}
+
+// movieField is a movie field that can be edited, synced and locked, by its
+// JSON name, which is also its column
+type movieField struct {
+	name string
+	same func(a, b *Movie) bool
+	copy func(dst, src *Movie)
+}
+
+var movieFields = []movieField{
+	{"imdb_id", func(a, b *Movie) bool { return a.IMDbID == b.IMDbID }, func(d, s *Movie) { d.IMDbID = s.IMDbID }},
+	{"title", func(a, b *Movie) bool { return a.Title == b.Title }, func(d, s *Movie) { d.Title = s.Title }},
+	{"original_title", func(a, b *Movie) bool { return a.OriginalTitle == b.OriginalTitle }, func(d, s *Movie) { d.OriginalTitle = s.OriginalTitle }},
+	{"original_language", func(a, b *Movie) bool { return a.OriginalLanguage == b.OriginalLanguage }, func(d, s *Movie) { d.OriginalLanguage = s.OriginalLanguage }},
+	{"tagline", func(a, b *Movie) bool { return a.Tagline == b.Tagline }, func(d, s *Movie) { d.Tagline = s.Tagline }},
+	{"description", func(a, b *Movie) bool { return a.Description == b.Description }, func(d, s *Movie) { d.Description = s.Description }},
+	{"status", func(a, b *Movie) bool { return a.Status == b.Status }, func(d, s *Movie) { d.Status = s.Status }},
+	{"poster_path", func(a, b *Movie) bool { return a.PosterPath == b.PosterPath }, func(d, s *Movie) { d.PosterPath = s.PosterPath }},
+	{"backdrop_path", func(a, b *Movie) bool { return a.BackdropPath == b.BackdropPath }, func(d, s *Movie) { d.BackdropPath = s.BackdropPath }},
+	{"release_date", func(a, b *Movie) bool { return a.ReleaseDate.Equal(b.ReleaseDate) }, func(d, s *Movie) { d.ReleaseDate = s.ReleaseDate }},
+	{"runtime", func(a, b *Movie) bool { return a.Runtime == b.Runtime }, func(d, s *Movie) { d.Runtime = s.Runtime }},
+	{"budget", func(a, b *Movie) bool { return a.Budget == b.Budget }, func(d, s *Movie) { d.Budget = s.Budget }},
+	{"revenue", func(a, b *Movie) bool { return a.Revenue == b.Revenue }, func(d, s *Movie) { d.Revenue = s.Revenue }},
+	{"rating", func(a, b *Movie) bool { return a.Rating == b.Rating }, func(d, s *Movie) { d.Rating = s.Rating }},
+	{"vote_count", func(a, b *Movie) bool { return a.VoteCount == b.VoteCount }, func(d, s *Movie) { d.VoteCount = s.VoteCount }},
+	{"genres", sameGenres, func(d, s *Movie) { d.Genres = s.Genres }},
+}
+
+// DetailFields are the fields only TMDB's movie details carry; its lists
+// leave them out. A sync keeps their stored values when TMDB gives none
+var DetailFields = []string{"imdb_id", "tagline", "status", "runtime", "budget", "revenue"}
+
+// sameGenres compares genre sets. A nil Genres slice on b leaves the stored
+// links alone, so it counts as the same
+func sameGenres(a, b *Movie) bool {
+	if b.Genres == nil {
+		return true
+	}
+	if len(a.Genres) != len(b.Genres) {
+		return false
+	}
+	ids := make(map[uint]bool, len(a.Genres))
+	for _, g := range a.Genres {
+		ids[g.ID] = true
+	}
+	for _, g := range b.Genres {
+		if !ids[g.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// MovieFields returns the names of the fields that track provenance and can
+// be locked
+func MovieFields() []string {
+	names := make([]string, len(movieFields))
+	for i, f := range movieFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// ValidMovieField reports whether name is one of MovieFields
+func ValidMovieField(name string) bool {
+	return slices.ContainsFunc(movieFields, func(f movieField) bool { return f.name == name })
+}
+
+// ChangedFields returns the fields whose values differ between stored and
+// updated
+func ChangedFields(stored, updated *Movie) []string {
+	var changed []string
+	for _, f := range movieFields {
+		if !f.same(stored, updated) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+// RecordEdit marks the fields an API edit changes from stored to edited as
+// manual, and under MergeLockField locks them. edited keeps the locks it
+// carries
+func (p MergePolicy) RecordEdit(stored, edited *Movie) {
+	changed := ChangedFields(stored, edited)
+	edited.ManualFields = union(stored.ManualFields, changed)
+	if p == MergeLockField {
+		edited.LockedFields = union(edited.LockedFields, changed)
+	}
+}
+
+// KeepLocked gives updated the stored values of the fields locked on stored
+func KeepLocked(stored, updated *Movie) {
+	for _, f := range movieFields {
+		if slices.Contains(stored.LockedFields, f.name) {
+			f.copy(updated, stored)
+		}
+	}
+}
+
+// KeepDetails gives updated the stored values of the DetailFields it has no
+// value for
+func KeepDetails(stored, updated *Movie) {
+	for _, f := range movieFields {
+		if slices.Contains(DetailFields, f.name) && f.same(&Movie{}, updated) {
+			f.copy(updated, stored)
+		}
+	}
+}
+
+// Merge prepares synced, fetched from TMDB, to be saved over stored: the
+// fields the policy protects keep their stored values and stay manual, the
+// rest take TMDB's values and become synced again. Detail fields TMDB gave
+// no value for keep theirs, as KeepDetails does. Locks are kept
+func (p MergePolicy) Merge(stored, synced *Movie) {
+	KeepDetails(stored, synced)
+	var manual []string
+	for _, f := range movieFields {
+		locked := slices.Contains(stored.LockedFields, f.name)
+		edited := slices.Contains(stored.ManualFields, f.name)
+		if locked || (edited && p != MergeUpstreamWins) {
+			f.copy(synced, stored)
+		}
+		if edited && f.same(stored, synced) {
+			manual = append(manual, f.name)
+		}
+	}
+	synced.ManualFields = manual
+	synced.LockedFields = slices.Clone(stored.LockedFields)
+}
+
+// union returns the names in a or b, in field order
+func union(a, b []string) []string {
+	var names []string
+	for _, f := range movieFields {
+		if slices.Contains(a, f.name) || slices.Contains(b, f.name) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
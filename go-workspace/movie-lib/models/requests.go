@@ -1,6 +1,12 @@
 // MovieQueryParams represents the query parameters for filtering and pagination
 package models
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 type MovieQueryParams struct {
 	Page        int      `query:"page" default:"1"`
 	Limit       int      `query:"limit" default:"10"`
@@ -9,13 +15,114 @@ type MovieQueryParams struct {
 	MaxRating   *float64 `query:"max_rating"`
 	ReleaseFrom string   `query:"release_from"`
 	ReleaseTo   string   `query:"release_to"`
+	// Genre and GenreAny take comma-separated genre IDs or names; Genre matches
+	// movies having all of them, GenreAny movies having at least one
+	Genre    string `query:"genre"`
+	GenreAny string `query:"genre_any"`
+	// Sort is a comma-separated list of SortFields, each prefixed with "-"
+	// for descending order, like "-rating,title". SortRelevance orders the
+	// matches of Search best first. Empty means DefaultSort
+	Sort string `query:"sort"`
+	// Cursor pages on from the next_cursor or prev_cursor of an earlier
+	// response, in place of Page
+	Cursor string `query:"cursor"`
+	// IncludeTotal false skips counting the matches; counting is the default
+	IncludeTotal *bool `query:"include_total"`
+	// Facets is a comma-separated list of Facets to count over the matches
+	Facets string `query:"facets"`
+}
+
+// FacetNames parses Facets, returning none without it
+func (p MovieQueryParams) FacetNames() ([]string, error) {
+	if p.Facets == "" {
+		return nil, nil
+	}
+	return ParseFacets(p.Facets)
+}
+
+// PageCursor decodes Cursor for the order of keys, or returns nil without
+// a cursor
+func (p MovieQueryParams) PageCursor(keys []SortKey) (*Cursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	return DecodeCursor(keys, p.Cursor)
+}
+
+// CountsTotal reports whether the total number of matches is wanted
+func (p MovieQueryParams) CountsTotal() bool {
+	return p.IncludeTotal == nil || *p.IncludeTotal
+}
+
+// SortRelevance orders search results by their rank
+const SortRelevance = "relevance"
+
+// DefaultSort lists the newest movies first
+const DefaultSort = "-created_at"
+
+// SortFields are the fields the movie listing can be sorted by, which are
+// also their columns
+var SortFields = []string{"title", "release_date", "rating", "created_at", "updated_at", "popularity"}
+
+// SortKey is one key of a sort order
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort order in the form of MovieQueryParams.Sort.
+// Fields may appear once; relevance has no "-" form
+func ParseSort(sort string) ([]SortKey, error) {
+	if sort == "" {
+		sort = DefaultSort
+	}
+	var keys []SortKey
+	for _, field := range strings.Split(sort, ",") {
+		key := SortKey{Field: strings.TrimSpace(field)}
+		if rest, ok := strings.CutPrefix(key.Field, "-"); ok {
+			key.Field, key.Desc = rest, true
+		}
+		switch {
+		case key.Field == SortRelevance && key.Desc:
+			return nil, fmt.Errorf("sort field %s has no descending form; it is always best first", SortRelevance)
+		case key.Field != SortRelevance && !slices.Contains(SortFields, key.Field):
+			return nil, fmt.Errorf("unknown sort field %q, use %s or %s", key.Field, strings.Join(SortFields, ", "), SortRelevance)
+		case slices.ContainsFunc(keys, func(k SortKey) bool { return k.Field == key.Field }):
+			return nil, fmt.Errorf("sort field %q appears twice", key.Field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SortKeys parses Sort, which may only order by relevance along with a
+// Search
+func (p MovieQueryParams) SortKeys() ([]SortKey, error) {
+	keys, err := ParseSort(p.Sort)
+	if err == nil && p.Search == "" && SortsByRelevance(keys) {
+		err = fmt.Errorf("sort=%s requires search", SortRelevance)
+	}
+	return keys, err
+}
+
+// SortsByRelevance reports whether keys order by search rank
+func SortsByRelevance(keys []SortKey) bool {
+	return slices.ContainsFunc(keys, func(k SortKey) bool { return k.Field == SortRelevance })
 }
 
 // PaginatedResponse represents the paginated response structure
 type PaginatedResponse struct {
-	Data       interface{} `json:"data"`
-	Total      int64       `json:"total"`
-	Page       int         `json:"page"`
-	Limit      int         `json:"limit"`
-	TotalPages int         `json:"totalPages"`
+	Data interface{} `json:"data"`
+	// Total and TotalPages are left out when the total isn't counted
+	Total *int64 `json:"total,omitempty"`
+	// Page is left out when paging by cursor
+	Page       int  `json:"page,omitempty"`
+	Limit      int  `json:"limit"`
+	TotalPages *int `json:"totalPages,omitempty"`
+	// NextCursor and PrevCursor page on after the last movie and before the
+	// first; they are left out at the ends of the listing
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Facets counts the matches per value of each requested facet, by facet
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
 }
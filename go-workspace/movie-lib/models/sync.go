@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// SyncReport summarises one TMDB sync. Every fetched movie is counted as
+// exactly one of created, updated, unchanged, skipped or failed
+type SyncReport struct {
+	Source     string      `json:"source"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	Fetched    int         `json:"fetched"`
+	Created    int         `json:"created"`
+	Updated    int         `json:"updated"`
+	Unchanged  int         `json:"unchanged"`
+	Skipped    int         `json:"skipped"`
+	Failed     int         `json:"failed"`
+	Errors     []SyncError `json:"errors,omitempty"`
+}
+
+// SyncError describes why a movie, or its credits, could not be synced
+type SyncError struct {
+	ExternalID string `json:"external_id"`
+	Title      string `json:"title"`
+	Error      string `json:"error"`
+}
+
+// SyncJobState is the lifecycle state of a background sync job
+type SyncJobState string
+
+// Sync job states. A source has at most one queued or running job
+const (
+	SyncJobQueued    SyncJobState = "queued"
+	SyncJobRunning   SyncJobState = "running"
+	SyncJobSucceeded SyncJobState = "succeeded"
+	SyncJobFailed    SyncJobState = "failed"
+)
+
+// Active reports whether a job in this state still blocks new jobs for its source
+func (s SyncJobState) Active() bool {
+	return s == SyncJobQueued || s == SyncJobRunning
+}
+
+// SyncProgress tells how far a running sync has got. Stage is "genres",
+// "changes", "details", "movies" or "credits"; Done and Total count the
+// items of that stage
+type SyncProgress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// SyncJob is a sync run in the background, kept after it finishes as history
+type SyncJob struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	Source     string       `json:"source" gorm:"not null"`
+	State      SyncJobState `json:"state" gorm:"not null"`
+	Progress   SyncProgress `json:"progress" gorm:"embedded;embeddedPrefix:progress_"`
+	Report     *SyncReport  `json:"report,omitempty" gorm:"serializer:json"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// SyncScheduleState is the state of a configured sync schedule shared by all
+// instances: whether it is paused and how its last run went
+type SyncScheduleState struct {
+	Name      string     `json:"name" gorm:"primaryKey"`
+	Paused    bool       `json:"paused" gorm:"not null;default:false"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastJobID *uint      `json:"last_job_id,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName maps the state to the sync_schedules table
+func (SyncScheduleState) TableName() string { return "sync_schedules" }
+
+// SyncSchedule is a configured sync schedule together with its state.
+// NextRunAt is when this instance will next try to run it
+type SyncSchedule struct {
+	SyncScheduleState
+	Source    string     `json:"source"`
+	Cron      string     `json:"cron"`
+	Jitter    string     `json:"jitter"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// SyncWatermark records up to when an incremental sync of a source has
+// caught up; the next run picks up TMDB's changes from there
+type SyncWatermark struct {
+	Source      string    `json:"source" gorm:"primaryKey"`
+	SyncedUntil time.Time `json:"synced_until" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
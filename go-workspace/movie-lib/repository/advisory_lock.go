@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// scheduleLockClass is the first key of the schedule advisory locks, keeping
+// them apart from the migration lock and from each other's applications
+const scheduleLockClass = 7411
+
+// AdvisoryLockElector elects a leader per key with Postgres session advisory
+// locks. The leader holds its lock on a connection taken out of the pool, so
+// the lead moves to another instance as soon as that connection dies. Each
+// held lead uses up one connection of the pool
+type AdvisoryLockElector struct {
+	db *sql.DB
+
+	mu    sync.Mutex
+	conns map[string]*sql.Conn
+}
+
+// NewAdvisoryLockElector creates an elector locking in the Postgres database db
+func NewAdvisoryLockElector(db *gorm.DB) (*AdvisoryLockElector, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return &AdvisoryLockElector{db: sqlDB, conns: make(map[string]*sql.Conn)}, nil
+}
+
+// TryLead reports whether this instance holds the lock of key, trying to
+// take it without waiting if not
+func (e *AdvisoryLockElector) TryLead(ctx context.Context, key string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if conn, ok := e.conns[key]; ok {
+		// The lock lasts as long as the session, so a dead connection lost it
+		if err := conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		conn.Close()
+		delete(e.conns, key)
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", scheduleLockClass, lockID(key)).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+	e.conns[key] = conn
+	return true, nil
+}
+
+// Resign releases the lock of key if this instance holds it
+func (e *AdvisoryLockElector) Resign(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	conn, ok := e.conns[key]
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, $2)", scheduleLockClass, lockID(key))
+	conn.Close()
+	delete(e.conns, key)
+}
+
+// lockID maps a key to the second key of its advisory lock
+func lockID(key string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int32(h.Sum32())
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+)
+
+// facetCounts are the counts the facets of a listing are built from. Years
+// also give the decades, and exact ratings the rating bands
+type facetCounts struct {
+	genres    []genreCount
+	years     map[int]int64
+	ratings   map[float64]int64
+	languages map[string]int64
+}
+
+type genreCount struct {
+	ID    uint
+	Name  string
+	Count int64
+}
+
+// buildFacets returns the named facets of counts. Genres and languages come
+// most common first, years, decades and rating bands highest first
+func buildFacets(names []string, counts facetCounts) map[string][]models.FacetCount {
+	facets := make(map[string][]models.FacetCount, len(names))
+	for _, name := range names {
+		var values []models.FacetCount
+		switch name {
+		case models.FacetGenre:
+			for _, g := range counts.genres {
+				values = append(values, models.FacetCount{Value: strconv.FormatUint(uint64(g.ID), 10), Name: g.Name, Count: g.Count})
+			}
+			slices.SortFunc(values, func(a, b models.FacetCount) int {
+				return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+			})
+		case models.FacetYear:
+			values = numberFacet(counts.years)
+		case models.FacetDecade:
+			decades := make(map[int]int64)
+			for year, count := range counts.years {
+				decades[year/10*10] += count
+			}
+			values = numberFacet(decades)
+		case models.FacetRating:
+			bands := make(map[int]int64)
+			for rating, count := range counts.ratings {
+				bands[min(int(math.Floor(rating)), 9)] += count
+			}
+			values = numberFacet(bands)
+		case models.FacetLanguage:
+			for language, count := range counts.languages {
+				values = append(values, models.FacetCount{Value: language, Count: count})
+			}
+			slices.SortFunc(values, func(a, b models.FacetCount) int {
+				return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value, b.Value))
+			})
+		}
+		if values == nil {
+			values = []models.FacetCount{}
+		}
+		facets[name] = values
+	}
+	return facets
+}
+
+// numberFacet lists counts by number, highest first
+func numberFacet(counts map[int]int64) []models.FacetCount {
+	values := make([]models.FacetCount, 0, len(counts))
+	for _, n := range slices.Sorted(maps.Keys(counts)) {
+		values = append(values, models.FacetCount{Value: strconv.Itoa(n), Count: counts[n]})
+	}
+	slices.Reverse(values)
+	return values
+}
+
+// countFacets counts what the named facets need over the movies whose IDs
+// matches selects
+func (r *GormRepository) countFacets(db *gorm.DB, matches *gorm.DB, names []string) (map[string][]models.FacetCount, error) {
+	var counts facetCounts
+	if slices.Contains(names, models.FacetGenre) {
+		if err := db.Table("movie_genres").
+			Select("genres.id, genres.name, COUNT(*) AS count").
+			Joins("JOIN genres ON genres.id = movie_genres.genre_id").
+			Where("movie_genres.movie_id IN (?)", matches).
+			Group("genres.id, genres.name").
+			Scan(&counts.genres).Error; err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(names, models.FacetYear) || slices.Contains(names, models.FacetDecade) {
+		var rows []struct {
+			Year  int
+			Count int64
+		}
+		year := r.dialect.year("release_date")
+		if err := db.Model(&models.Movie{}).
+			Select(year+" AS year, COUNT(*) AS count").
+			Where("id IN (?) AND release_date IS NOT NULL", matches).
+			Group(year).
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		counts.years = make(map[int]int64, len(rows))
+		for _, row := range rows {
+			// The zero release date of year 1 stands for an unknown one
+			if row.Year > 1 {
+				counts.years[row.Year] += row.Count
+			}
+		}
+	}
+	if slices.Contains(names, models.FacetRating) {
+		var rows []struct {
+			Rating float64
+			Count  int64
+		}
+		if err := db.Model(&models.Movie{}).
+			Select("rating, COUNT(*) AS count").
+			Where("id IN (?)", matches).
+			Group("rating").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		counts.ratings = make(map[float64]int64, len(rows))
+		for _, row := range rows {
+			counts.ratings[row.Rating] = row.Count
+		}
+	}
+	if slices.Contains(names, models.FacetLanguage) {
+		var rows []struct {
+			Language string
+			Count    int64
+		}
+		if err := db.Model(&models.Movie{}).
+			Select("original_language AS language, COUNT(*) AS count").
+			Where("id IN (?) AND original_language <> ''", matches).
+			Group("original_language").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		counts.languages = make(map[string]int64, len(rows))
+		for _, row := range rows {
+			counts.languages[row.Language] = row.Count
+		}
+	}
+	return buildFacets(names, counts), nil
+}
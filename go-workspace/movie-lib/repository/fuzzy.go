@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// fuzzyThreshold is the word similarity a title needs with a query to match
+// it fuzzily: the share of the query's trigrams found in the title
+const fuzzyThreshold = 0.5
+
+// foldText lowercases s and strips its accents, like fold_text on Postgres
+func foldText(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// words returns the folded words of s, split the way pg_trgm splits them
+func words(s string) []string {
+	return strings.FieldsFunc(foldText(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordPrefixes returns the distinct first n characters of the words of s
+func wordPrefixes(s string, n int) []string {
+	var prefixes []string
+	for _, word := range words(s) {
+		prefix := word
+		if r := []rune(word); len(r) > n {
+			prefix = string(r[:n])
+		}
+		if !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// trigrams returns the trigrams of the words of s the way pg_trgm extracts
+// them: each word is padded with two spaces before and one after
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// wordSimilarity approximates pg_trgm's word_similarity: the share of the
+// trigrams of query that text contains, from 0 to 1
+func wordSimilarity(query, text string) float64 {
+	q := trigrams(query)
+	if len(q) == 0 {
+		return 0
+	}
+	t := trigrams(text)
+	common := 0
+	for tri := range q {
+		if t[tri] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(q))
+}
+
+// suggestTitles returns up to limit of movies whose titles are similar to
+// query, most similar first, then the most voted
+func suggestTitles(movies []models.Movie, query string, limit int) []models.MovieSuggestion {
+	type scored struct {
+		movie      models.Movie
+		similarity float64
+	}
+	var matches []scored
+	for _, m := range movies {
+		if s := wordSimilarity(query, m.Title); s >= fuzzyThreshold {
+			matches = append(matches, scored{m, s})
+		}
+	}
+	slices.SortFunc(matches, func(a, b scored) int {
+		return cmp.Or(
+			cmp.Compare(b.similarity, a.similarity),
+			cmp.Compare(b.movie.VoteCount, a.movie.VoteCount),
+			cmp.Compare(a.movie.ID, b.movie.ID),
+		)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	suggestions := make([]models.MovieSuggestion, len(matches))
+	for i, m := range matches {
+		suggestions[i] = newMovieSuggestion(m.movie)
+	}
+	return suggestions
+}
+
+// newMovieSuggestion suggests movie
+func newMovieSuggestion(movie models.Movie) models.MovieSuggestion {
+	s := models.MovieSuggestion{ID: movie.ID, Title: movie.Title}
+	if !movie.ReleaseDate.IsZero() {
+		s.Year = movie.ReleaseDate.Year()
+	}
+	return s
+}
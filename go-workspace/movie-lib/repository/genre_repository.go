@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveGenres upserts the genre catalogue by TMDB genre ID
+func (r *GormRepository) SaveGenres(ctx context.Context, genres []models.Genre) error {
+	if len(genres) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name"}),
+		}).
+		Create(&genres).Error
+}
+
+// GetAllGenres retrieves the genre catalogue ordered by name
+func (r *GormRepository) GetAllGenres(ctx context.Context) ([]models.Genre, error) {
+	var genres []models.Genre
+	if err := r.db.WithContext(ctx).Order("name").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// replaceMovieGenres makes the movie's genre links match movie.Genres.
+// A nil Genres slice leaves the existing links untouched
+func replaceMovieGenres(db *gorm.DB, movie *models.Movie) error {
+	if movie.Genres == nil || movie.ID == 0 {
+		return nil
+	}
+	return db.Model(movie).Omit("Genres.*").Association("Genres").Replace(movie.Genres)
+}
+
+// resolveGenreIDs turns a comma-separated list of genre IDs and/or names into
+// genre IDs. unknown reports whether any entry matched no genre
+func resolveGenreIDs(db *gorm.DB, list string) (ids []uint, unknown bool, err error) {
+	var names []string
+	for _, token := range strings.Split(list, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(token, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		} else {
+			names = append(names, strings.ToLower(token))
+		}
+	}
+
+	if len(names) > 0 {
+		var found []models.Genre
+		if err := db.Where("LOWER(name) IN ?", names).Find(&found).Error; err != nil {
+			return nil, false, err
+		}
+		for _, g := range found {
+			ids = append(ids, g.ID)
+		}
+		unknown = len(found) < len(names)
+	}
+
+	return ids, unknown, nil
+}
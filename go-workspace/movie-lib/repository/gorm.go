@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormRepository is the Repository backed by a GORM database. Use
+// NewPostgresRepository or NewSQLiteRepository to create one
+type GormRepository struct {
+	db      *gorm.DB
+	dialect dialect
+}
+
+// dialect holds the SQL that differs between the supported databases
+type dialect interface {
+	// contains returns a condition matching rows whose column contains the
+	// bound pattern, ignoring case
+	contains(column string) string
+	// search returns a condition matching the movies found by terms and an
+	// expression ranking them, higher first
+	search(terms string) (cond, rank clause.Expr)
+	// prepareSearch sets up the transaction tx for the search condition
+	prepareSearch(tx *gorm.DB) error
+	// refreshSearch updates the search document of a movie after its
+	// credits change
+	refreshSearch(tx *gorm.DB, movieID uint) error
+	// year returns the UTC year of a timestamp column as an integer
+	year(column string) string
+	// suggestTitles returns up to limit movies whose titles are similar to
+	// query, most similar first
+	suggestTitles(db *gorm.DB, query string, limit int) ([]models.MovieSuggestion, error)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) contains(column string) string {
+	return fmt.Sprintf("%s ILIKE ?", column)
+}
+
+// search runs a full-text query in web search syntax: quoted phrases, "or"
+// and "-" to exclude a word. Titles similar to terms match too, so typos and
+// missing accents are forgiven. Both sides of the condition are operators
+// the indexes serve
+func (postgresDialect) search(terms string) (cond, rank clause.Expr) {
+	return clause.Expr{
+			SQL:  "movies.search_vector @@ websearch_to_tsquery('english', ?) OR fold_text(?) <% fold_text(movies.title)",
+			Vars: []interface{}{terms, terms},
+		},
+		clause.Expr{
+			SQL:  "ts_rank(movies.search_vector, websearch_to_tsquery('english', ?)) + word_similarity(fold_text(?), fold_text(movies.title))",
+			Vars: []interface{}{terms, terms},
+		}
+}
+
+// prepareSearch gives <% the fuzzy threshold for the rest of tx
+func (postgresDialect) prepareSearch(tx *gorm.DB) error {
+	return tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", strconv.FormatFloat(fuzzyThreshold, 'f', -1, 64)).Error
+}
+
+func (postgresDialect) refreshSearch(tx *gorm.DB, movieID uint) error {
+	return tx.Exec("UPDATE movies SET search_vector = movie_search_vector(id, title, description) WHERE id = ?", movieID).Error
+}
+
+func (postgresDialect) year(column string) string {
+	return fmt.Sprintf("CAST(EXTRACT(YEAR FROM %s AT TIME ZONE 'UTC') AS INTEGER)", column)
+}
+
+// suggestTitles walks the title trigram index nearest first
+func (postgresDialect) suggestTitles(db *gorm.DB, query string, limit int) ([]models.MovieSuggestion, error) {
+	var movies []models.Movie
+	if err := db.
+		Select("id", "title", "release_date").
+		Where("fold_text(?) <<-> fold_text(title) <= ?", query, 1-fuzzyThreshold).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "fold_text(?) <<-> fold_text(title), vote_count DESC, id", Vars: []interface{}{query}}}).
+		Limit(limit).
+		Find(&movies).Error; err != nil {
+		return nil, err
+	}
+	suggestions := make([]models.MovieSuggestion, len(movies))
+	for i, m := range movies {
+		suggestions[i] = newMovieSuggestion(m)
+	}
+	return suggestions, nil
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) contains(column string) string {
+	// SQLite's LIKE is already case-insensitive for ASCII; LOWER covers the rest
+	return fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", column)
+}
+
+// search matches terms as a substring of the title or description, ranking
+// title matches first
+func (d sqliteDialect) search(terms string) (cond, rank clause.Expr) {
+	pattern := "%" + terms + "%"
+	return clause.Expr{SQL: d.contains("title") + " OR " + d.contains("description"), Vars: []interface{}{pattern, pattern}},
+		clause.Expr{
+			SQL:  fmt.Sprintf("(CASE WHEN %s THEN %g ELSE 0 END + CASE WHEN %s THEN %g ELSE 0 END)", d.contains("title"), titleRank, d.contains("description"), descriptionRank),
+			Vars: []interface{}{pattern, pattern},
+		}
+}
+
+func (sqliteDialect) prepareSearch(*gorm.DB) error {
+	return nil
+}
+
+func (sqliteDialect) refreshSearch(*gorm.DB, uint) error {
+	return nil
+}
+
+// year reads the year off the stored text; times are stored in UTC
+func (sqliteDialect) year(column string) string {
+	return fmt.Sprintf("CAST(substr(%s, 1, 4) AS INTEGER)", column)
+}
+
+// suggestTitles scores titles in Go, as SQLite has no trigrams. Only titles
+// containing the first two characters of a word of query are loaded. LIKE
+// doesn't fold accents, so a title accented in those characters is missed
+func (sqliteDialect) suggestTitles(db *gorm.DB, query string, limit int) ([]models.MovieSuggestion, error) {
+	prefixes := wordPrefixes(query, 2)
+	if len(prefixes) == 0 {
+		return []models.MovieSuggestion{}, nil
+	}
+	conds := make([]string, len(prefixes))
+	vars := make([]interface{}, len(prefixes))
+	for i, prefix := range prefixes {
+		conds[i] = "title LIKE ?"
+		vars[i] = "%" + prefix + "%"
+	}
+
+	var movies []models.Movie
+	if err := db.Select("id", "title", "release_date").Where(strings.Join(conds, " OR "), vars...).Find(&movies).Error; err != nil {
+		return nil, err
+	}
+	return suggestTitles(movies, query, limit), nil
+}
+
+// The ranks of substring matches, where full-text search isn't available
+const (
+	titleRank       = 1.0
+	descriptionRank = 0.5
+)
+
+// NewPostgresRepository creates a repository for a Postgres database
+func NewPostgresRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db, dialect: postgresDialect{}}
+}
+
+// NewSQLiteRepository creates a repository for a SQLite database, for local
+// development and tests
+func NewSQLiteRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{db: db, dialect: sqliteDialect{}}
+}
+
+// NewGormRepository creates the repository matching the database's dialect
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	if db.Dialector.Name() == "sqlite" {
+		return NewSQLiteRepository(db)
+	}
+	return NewPostgresRepository(db)
+}
+
+// Transaction runs fn inside a database transaction, or a savepoint when
+// already inside one
+func (r *GormRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormRepository{db: tx, dialect: r.dialect})
+	})
+}
+
+// DB returns the underlying database handle
+func (r *GormRepository) DB() *gorm.DB {
+	return r.db
+}
+
+var _ Repository = (*GormRepository)(nil)
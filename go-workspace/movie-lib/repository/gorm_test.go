@@ -0,0 +1,86 @@
+package repository_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rohankarmacharya/movie-lib/config"
+	"github.com/rohankarmacharya/movie-lib/migrate"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/repository/repositorytest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresDSNEnv names the database the Postgres suite runs against. The
+// suite empties its tables, so point it at a throwaway database
+const postgresDSNEnv = "MOVIE_TEST_POSTGRES_DSN"
+
+func TestSQLiteRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		cfg := config.Default().DB
+		cfg.Driver = "sqlite"
+		cfg.Path = filepath.Join(t.TempDir(), "movies.db")
+		db, err := config.ConnectDB(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Logger = logger.Discard
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		migrateUp(t, db)
+		return repository.NewSQLiteRepository(db)
+	})
+}
+
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrateUp(t, db)
+
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		truncate(t, db)
+		return repository.NewPostgresRepository(db)
+	})
+}
+
+func migrateUp(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if err := migrate.New(db, migrate.All).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
+
+// truncate empties every table but the migrations' own, restarting the IDs
+func truncate(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	var tables []string
+	if err := db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'").
+		Scan(&tables).Error; err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+	if err := db.Exec(fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE", strings.Join(tables, ", "))).Error; err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+}
@@ -0,0 +1,1064 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+)
+
+// MemoryRepository is a Repository kept entirely in memory. It is safe for
+// concurrent use and meant for tests and local experiments
+type MemoryRepository struct {
+	// writeMu serializes writers, including whole transactions; mu guards
+	// the state itself so reads can run alongside a transaction
+	writeMu     sync.Mutex
+	mu          sync.RWMutex
+	movies      map[uint]*models.Movie
+	movieGenres map[uint][]uint
+	genres      map[uint]models.Genre
+	people      map[uint]*models.Person
+	credits     map[uint]models.Credit
+	syncJobs    map[uint]*models.SyncJob
+	schedules   map[string]models.SyncScheduleState
+	watermarks  map[string]models.SyncWatermark
+
+	nextMovieID   uint
+	nextPersonID  uint
+	nextCreditID  uint
+	nextSyncJobID uint
+}
+
+var _ Repository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		movies:      make(map[uint]*models.Movie),
+		movieGenres: make(map[uint][]uint),
+		genres:      make(map[uint]models.Genre),
+		people:      make(map[uint]*models.Person),
+		credits:     make(map[uint]models.Credit),
+		syncJobs:    make(map[uint]*models.SyncJob),
+		schedules:   make(map[string]models.SyncScheduleState),
+		watermarks:  make(map[string]models.SyncWatermark),
+	}
+}
+
+// CreateMovie creates a new movie
+func (r *MemoryRepository) CreateMovie(ctx context.Context, movie *models.Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upsertMovie(movie, time.Now())
+	return nil
+}
+
+// SaveMovies saves multiple movies and returns the count of saved movies
+func (r *MemoryRepository) SaveMovies(ctx context.Context, movies []models.Movie) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range movies {
+		r.upsertMovie(&movies[i], now)
+	}
+	return int64(len(movies)), nil
+}
+
+// upsertMovie inserts movie, or refreshes the stored movie with the same
+// non-empty external_id, keeping its locked fields, provenance and locks.
+// The caller must hold the write lock
+func (r *MemoryRepository) upsertMovie(movie *models.Movie, now time.Time) {
+	if movie.CreatedAt.IsZero() {
+		movie.CreatedAt = now
+	}
+	if movie.UpdatedAt.IsZero() {
+		movie.UpdatedAt = now
+	}
+
+	if movie.ExternalID != "" {
+		for id, existing := range r.movies {
+			if existing.ExternalID == movie.ExternalID {
+				movie.ID = id
+				stored := copyMovie(movie)
+				stored.CreatedAt = existing.CreatedAt
+				models.KeepLocked(existing, stored)
+				models.KeepDetails(existing, stored)
+				stored.ManualFields = existing.ManualFields
+				stored.LockedFields = existing.LockedFields
+				r.movies[id] = stored
+				if !slices.Contains(existing.LockedFields, "genres") {
+					r.setMovieGenres(movie)
+				}
+				return
+			}
+		}
+	}
+
+	if movie.ID == 0 {
+		r.nextMovieID++
+		movie.ID = r.nextMovieID
+	} else if movie.ID > r.nextMovieID {
+		r.nextMovieID = movie.ID
+	}
+	r.movies[movie.ID] = copyMovie(movie)
+	r.setMovieGenres(movie)
+}
+
+// setMovieGenres mirrors replaceMovieGenres: a nil Genres slice leaves the
+// existing links untouched
+func (r *MemoryRepository) setMovieGenres(movie *models.Movie) {
+	if movie.Genres == nil {
+		return
+	}
+	ids := make([]uint, 0, len(movie.Genres))
+	for _, g := range movie.Genres {
+		ids = append(ids, g.ID)
+	}
+	r.movieGenres[movie.ID] = uniqueIDs(ids)
+}
+
+// GetMovieByID gets a movie by ID
+func (r *MemoryRepository) GetMovieByID(ctx context.Context, id uint) (*models.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	movie, ok := r.movies[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := r.withGenres(movie)
+	return &result, nil
+}
+
+// UpdateMovie updates an existing movie, keeping its creation time
+func (r *MemoryRepository) UpdateMovie(ctx context.Context, movie *models.Movie) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.movies[movie.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	movie.CreatedAt = existing.CreatedAt
+	movie.UpdatedAt = time.Now()
+	r.movies[movie.ID] = copyMovie(movie)
+	r.setMovieGenres(movie)
+	return nil
+}
+
+// DeleteMovie deletes a movie by ID along with its genre links and credits
+func (r *MemoryRepository) DeleteMovie(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.movies[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.movies, id)
+	delete(r.movieGenres, id)
+	for creditID, c := range r.credits {
+		if c.MovieID == id {
+			delete(r.credits, creditID)
+		}
+	}
+	return nil
+}
+
+// GetAllMovies retrieves all movies
+func (r *MemoryRepository) GetAllMovies(ctx context.Context) ([]models.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	movies := make([]models.Movie, 0, len(r.movies))
+	for _, id := range r.sortedMovieIDs() {
+		movies = append(movies, r.withGenres(r.movies[id]))
+	}
+	return movies, nil
+}
+
+// GetMoviesByExternalIDs retrieves the movies with the given external IDs
+func (r *MemoryRepository) GetMoviesByExternalIDs(ctx context.Context, externalIDs []string) ([]models.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(externalIDs))
+	for _, id := range externalIDs {
+		if id != "" {
+			wanted[id] = true
+		}
+	}
+	movies := []models.Movie{}
+	for _, id := range r.sortedMovieIDs() {
+		if movie := r.movies[id]; wanted[movie.ExternalID] {
+			movies = append(movies, r.withGenres(movie))
+		}
+	}
+	return movies, nil
+}
+
+// GetMovieByTitleAndDate finds a movie by its title and release date
+func (r *MemoryRepository) GetMovieByTitleAndDate(ctx context.Context, title string, releaseDate time.Time) (*models.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, id := range r.sortedMovieIDs() {
+		movie := r.movies[id]
+		if movie.Title == title && movie.ReleaseDate.Equal(releaseDate) {
+			result := copyMovie(movie)
+			result.Genres = nil
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetMoviesWithPagination retrieves movies with filtering, searching, and pagination
+func (r *MemoryRepository) GetMoviesWithPagination(ctx context.Context, params models.MovieQueryParams) (*models.PaginatedResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys, err := params.SortKeys()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := params.PageCursor(keys)
+	if err != nil {
+		return nil, err
+	}
+	facetNames, err := params.FacetNames()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var from, to time.Time
+	if params.ReleaseFrom != "" {
+		from, _ = time.Parse("2006-01-02", params.ReleaseFrom)
+	}
+	if params.ReleaseTo != "" {
+		if parsed, err := time.Parse("2006-01-02", params.ReleaseTo); err == nil {
+			to = parsed.Add(24 * time.Hour)
+		}
+	}
+
+	var allIDs, anyIDs []uint
+	matchNone := false
+	if params.Genre != "" {
+		ids, unknown := r.resolveGenreIDs(params.Genre)
+		allIDs = uniqueIDs(ids)
+		matchNone = matchNone || unknown
+	}
+	if params.GenreAny != "" {
+		anyIDs, _ = r.resolveGenreIDs(params.GenreAny)
+		matchNone = matchNone || len(anyIDs) == 0
+	}
+
+	// Like the SQLite dialect, search matches substrings and ranks title
+	// matches first
+	search := strings.ToLower(params.Search)
+	ranks := make(map[uint]float64)
+	var matched []*models.Movie
+	if !matchNone {
+		for _, movie := range r.movies {
+			if search != "" {
+				var rank float64
+				if strings.Contains(strings.ToLower(movie.Title), search) {
+					rank += titleRank
+				}
+				if strings.Contains(strings.ToLower(movie.Description), search) {
+					rank += descriptionRank
+				}
+				if rank == 0 {
+					continue
+				}
+				ranks[movie.ID] = rank
+			}
+			if params.MinRating != nil && movie.Rating < *params.MinRating {
+				continue
+			}
+			if params.MaxRating != nil && movie.Rating > *params.MaxRating {
+				continue
+			}
+			if !from.IsZero() && movie.ReleaseDate.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !movie.ReleaseDate.Before(to) {
+				continue
+			}
+			if len(allIDs) > 0 && !containsAll(r.movieGenres[movie.ID], allIDs) {
+				continue
+			}
+			if len(anyIDs) > 0 && !containsAny(r.movieGenres[movie.ID], anyIDs) {
+				continue
+			}
+			matched = append(matched, movie)
+		}
+	}
+
+	// Sort copies carrying their ranks, which the cursor's movie has too
+	hits := make([]models.Movie, len(matched))
+	for i, movie := range matched {
+		hits[i] = *movie
+		if search != "" {
+			rank := ranks[movie.ID]
+			hits[i].Rank = &rank
+		}
+	}
+	slices.SortFunc(hits, func(a, b models.Movie) int {
+		return compareMovies(&a, &b, keys)
+	})
+
+	var total *int64
+	if params.CountsTotal() {
+		count := int64(len(hits))
+		total = &count
+	}
+
+	var start, end int
+	var more bool
+	switch {
+	case cursor == nil:
+		start = min(max((params.Page-1)*params.Limit, 0), len(hits))
+		end = min(start+params.Limit, len(hits))
+		more = end < len(hits)
+	case cursor.Before:
+		end = sort.Search(len(hits), func(i int) bool { return compareMovies(&hits[i], &cursor.Pivot, keys) >= 0 })
+		start = max(end-params.Limit, 0)
+		more = start > 0
+	default:
+		start = sort.Search(len(hits), func(i int) bool { return compareMovies(&hits[i], &cursor.Pivot, keys) > 0 })
+		end = min(start+params.Limit, len(hits))
+		more = end < len(hits)
+	}
+
+	movies := make([]models.Movie, 0, end-start)
+	for _, hit := range hits[start:end] {
+		movie := r.withGenres(&hit)
+		movie.Rank = hit.Rank
+		movies = append(movies, movie)
+	}
+
+	response := pageResponse(params, keys, cursor, movies, more, total)
+	if len(facetNames) > 0 {
+		response.Facets = buildFacets(facetNames, r.countFacets(matched))
+	}
+	return response, nil
+}
+
+// countFacets counts the facets of movies like the GORM countFacets
+func (r *MemoryRepository) countFacets(movies []*models.Movie) facetCounts {
+	counts := facetCounts{years: make(map[int]int64), ratings: make(map[float64]int64), languages: make(map[string]int64)}
+	genres := make(map[uint]int64)
+	for _, movie := range movies {
+		for _, id := range r.movieGenres[movie.ID] {
+			genres[id]++
+		}
+		if year := movie.ReleaseDate.UTC().Year(); year > 1 {
+			counts.years[year]++
+		}
+		counts.ratings[movie.Rating]++
+		if movie.OriginalLanguage != "" {
+			counts.languages[movie.OriginalLanguage]++
+		}
+	}
+	for id, count := range genres {
+		if g, ok := r.genres[id]; ok {
+			counts.genres = append(counts.genres, genreCount{ID: id, Name: g.Name, Count: count})
+		}
+	}
+	return counts
+}
+
+// compareMovies orders a and b like the GORM sortTerms: by keys, relevance
+// by Rank, then by id in the direction of the last key
+func compareMovies(a, b *models.Movie, keys []models.SortKey) int {
+	desc := false
+	for _, key := range keys {
+		var c int
+		desc = key.Desc
+		switch key.Field {
+		case models.SortRelevance:
+			c, desc = cmp.Compare(rankOf(a), rankOf(b)), true
+		case "title":
+			c = strings.Compare(a.Title, b.Title)
+		case "release_date":
+			c = a.ReleaseDate.Compare(b.ReleaseDate)
+		case "rating":
+			c = cmp.Compare(a.Rating, b.Rating)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "popularity":
+			c = cmp.Compare(a.Popularity, b.Popularity)
+		}
+		if c != 0 {
+			if desc {
+				return -c
+			}
+			return c
+		}
+	}
+	if desc {
+		return cmp.Compare(b.ID, a.ID)
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func rankOf(movie *models.Movie) float64 {
+	if movie.Rank == nil {
+		return 0
+	}
+	return *movie.Rank
+}
+
+// SuggestMovies returns up to limit movies whose titles resemble query,
+// scored like the SQLite dialect does
+func (r *MemoryRepository) SuggestMovies(ctx context.Context, query string, limit int) ([]models.MovieSuggestion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	movies := make([]models.Movie, 0, len(r.movies))
+	for _, movie := range r.movies {
+		movies = append(movies, *movie)
+	}
+	return suggestTitles(movies, query, limit), nil
+}
+
+// resolveGenreIDs mirrors the GORM version: entries are genre IDs or
+// case-insensitive names, and unknown reports whether a name matched nothing
+func (r *MemoryRepository) resolveGenreIDs(list string) (ids []uint, unknown bool) {
+	for _, token := range strings.Split(list, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(token, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+			continue
+		}
+		found := false
+		for _, g := range r.genres {
+			if strings.EqualFold(g.Name, token) {
+				ids = append(ids, g.ID)
+				found = true
+			}
+		}
+		unknown = unknown || !found
+	}
+	return ids, unknown
+}
+
+// SaveGenres upserts the genre catalogue by TMDB genre ID
+func (r *MemoryRepository) SaveGenres(ctx context.Context, genres []models.Genre) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, g := range genres {
+		r.genres[g.ID] = g
+	}
+	return nil
+}
+
+// GetAllGenres retrieves the genre catalogue ordered by name
+func (r *MemoryRepository) GetAllGenres(ctx context.Context) ([]models.Genre, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	genres := make([]models.Genre, 0, len(r.genres))
+	for _, g := range r.genres {
+		genres = append(genres, g)
+	}
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Name < genres[j].Name })
+	return genres, nil
+}
+
+// SaveMovieCredits replaces the credits of a movie, upserting the people they
+// refer to by external_id
+func (r *MemoryRepository) SaveMovieCredits(ctx context.Context, movieID uint, credits []models.Credit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	people := make(map[string]uint)
+	for _, c := range credits {
+		if c.Person == nil {
+			continue
+		}
+		if _, ok := people[c.Person.ExternalID]; ok {
+			continue
+		}
+		people[c.Person.ExternalID] = r.upsertPerson(*c.Person, now)
+	}
+
+	for id, c := range r.credits {
+		if c.MovieID == movieID {
+			delete(r.credits, id)
+		}
+	}
+
+	for _, c := range credits {
+		if c.Person != nil {
+			c.PersonID = people[c.Person.ExternalID]
+		}
+		r.nextCreditID++
+		c.ID = r.nextCreditID
+		c.MovieID = movieID
+		c.Person = nil
+		c.Movie = nil
+		r.credits[c.ID] = c
+	}
+	return nil
+}
+
+// upsertPerson stores p, or refreshes the person with the same external_id,
+// and returns its ID. The caller must hold the write lock
+func (r *MemoryRepository) upsertPerson(p models.Person, now time.Time) uint {
+	for id, existing := range r.people {
+		if existing.ExternalID == p.ExternalID {
+			existing.Name = p.Name
+			existing.ProfilePath = p.ProfilePath
+			existing.KnownForDepartment = p.KnownForDepartment
+			existing.UpdatedAt = now
+			return id
+		}
+	}
+	r.nextPersonID++
+	p.ID = r.nextPersonID
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	r.people[p.ID] = &p
+	return p.ID
+}
+
+// GetMovieCredits retrieves the cast and crew of a movie, in billing order
+func (r *MemoryRepository) GetMovieCredits(ctx context.Context, movieID uint) (*models.MovieCredits, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credits []models.Credit
+	for _, c := range r.credits {
+		if c.MovieID == movieID {
+			if p, ok := r.people[c.PersonID]; ok {
+				person := *p
+				c.Person = &person
+			}
+			credits = append(credits, c)
+		}
+	}
+	sort.Slice(credits, func(i, j int) bool {
+		if credits[i].Order != credits[j].Order {
+			return credits[i].Order < credits[j].Order
+		}
+		return credits[i].ID < credits[j].ID
+	})
+
+	result := &models.MovieCredits{
+		MovieID: movieID,
+		Cast:    []models.Credit{},
+		Crew:    []models.Credit{},
+	}
+	for _, c := range credits {
+		if c.Role == models.CreditRoleCast {
+			result.Cast = append(result.Cast, c)
+		} else {
+			result.Crew = append(result.Crew, c)
+		}
+	}
+	return result, nil
+}
+
+// GetPersonByID gets a person by ID
+func (r *MemoryRepository) GetPersonByID(ctx context.Context, id uint) (*models.Person, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.people[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	person := *p
+	return &person, nil
+}
+
+// GetPersonCredits retrieves every credit of a person with the movie attached,
+// newest release first
+func (r *MemoryRepository) GetPersonCredits(ctx context.Context, personID uint) ([]models.Credit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credits := []models.Credit{}
+	for _, c := range r.credits {
+		if c.PersonID != personID {
+			continue
+		}
+		if m, ok := r.movies[c.MovieID]; ok {
+			movie := copyMovie(m)
+			movie.Genres = nil
+			c.Movie = movie
+		}
+		credits = append(credits, c)
+	}
+	sort.Slice(credits, func(i, j int) bool {
+		a, b := releaseDateOf(credits[i]), releaseDateOf(credits[j])
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return credits[i].ID < credits[j].ID
+	})
+	return credits, nil
+}
+
+// Transaction runs fn against a copy of the repository and swaps the copy in
+// if fn succeeds. Other writers wait until the transaction ends
+func (r *MemoryRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	r.mu.RLock()
+	tx := r.clone()
+	r.mu.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.movies, r.movieGenres, r.genres, r.people, r.credits = tx.movies, tx.movieGenres, tx.genres, tx.people, tx.credits
+	r.syncJobs, r.schedules, r.watermarks = tx.syncJobs, tx.schedules, tx.watermarks
+	r.nextMovieID, r.nextPersonID, r.nextCreditID = tx.nextMovieID, tx.nextPersonID, tx.nextCreditID
+	r.nextSyncJobID = tx.nextSyncJobID
+	return nil
+}
+
+// clone returns a deep copy of the repository. The caller must hold a lock
+func (r *MemoryRepository) clone() *MemoryRepository {
+	c := NewMemoryRepository()
+	for id, m := range r.movies {
+		c.movies[id] = copyMovie(m)
+	}
+	for id, genres := range r.movieGenres {
+		c.movieGenres[id] = append([]uint(nil), genres...)
+	}
+	for id, g := range r.genres {
+		c.genres[id] = g
+	}
+	for id, p := range r.people {
+		person := *p
+		c.people[id] = &person
+	}
+	for id, credit := range r.credits {
+		c.credits[id] = credit
+	}
+	for id, job := range r.syncJobs {
+		c.syncJobs[id] = copySyncJob(job)
+	}
+	for name, state := range r.schedules {
+		c.schedules[name] = copyScheduleState(state)
+	}
+	for source, mark := range r.watermarks {
+		c.watermarks[source] = mark
+	}
+	c.nextMovieID, c.nextPersonID, c.nextCreditID = r.nextMovieID, r.nextPersonID, r.nextCreditID
+	c.nextSyncJobID = r.nextSyncJobID
+	return c
+}
+
+// CreateSyncJob stores a new sync job unless its source already has an active one
+func (r *MemoryRepository) CreateSyncJob(ctx context.Context, job *models.SyncJob) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.activeSyncJob(job.Source) != nil {
+		return ErrSyncJobActive
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	r.nextSyncJobID++
+	job.ID = r.nextSyncJobID
+	r.syncJobs[job.ID] = copySyncJob(job)
+	return nil
+}
+
+// UpdateSyncJob saves the state, progress and outcome of a sync job
+func (r *MemoryRepository) UpdateSyncJob(ctx context.Context, job *models.SyncJob) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.syncJobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	job.UpdatedAt = time.Now()
+	r.syncJobs[job.ID] = copySyncJob(job)
+	return nil
+}
+
+// TouchSyncJob bumps the updated_at of an active sync job
+func (r *MemoryRepository) TouchSyncJob(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job, ok := r.syncJobs[id]; ok && job.State.Active() {
+		job.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// GetSyncJob gets a sync job by ID
+func (r *MemoryRepository) GetSyncJob(ctx context.Context, id uint) (*models.SyncJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	job, ok := r.syncJobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copySyncJob(job), nil
+}
+
+// GetActiveSyncJob gets the queued or running job of a source
+func (r *MemoryRepository) GetActiveSyncJob(ctx context.Context, source string) (*models.SyncJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if job := r.activeSyncJob(source); job != nil {
+		return copySyncJob(job), nil
+	}
+	return nil, nil
+}
+
+// activeSyncJob returns the stored active job of source. The caller must hold a lock
+func (r *MemoryRepository) activeSyncJob(source string) *models.SyncJob {
+	for _, job := range r.syncJobs {
+		if job.Source == source && job.State.Active() {
+			return job
+		}
+	}
+	return nil
+}
+
+// ListSyncJobs retrieves the most recent sync jobs
+func (r *MemoryRepository) ListSyncJobs(ctx context.Context, limit int) ([]models.SyncJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make([]models.SyncJob, 0, len(r.syncJobs))
+	for _, job := range r.syncJobs {
+		jobs = append(jobs, *copySyncJob(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID > jobs[j].ID
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// FailActiveSyncJobs marks active jobs that stopped reporting progress as failed
+func (r *MemoryRepository) FailActiveSyncJobs(ctx context.Context, updatedBefore time.Time, message string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var failed int64
+	for _, job := range r.syncJobs {
+		if !job.State.Active() || !job.UpdatedAt.Before(updatedBefore) {
+			continue
+		}
+		job.State = models.SyncJobFailed
+		job.Error = message
+		job.FinishedAt = &now
+		job.UpdatedAt = now
+		failed++
+	}
+	return failed, nil
+}
+
+// GetSyncScheduleStates retrieves the stored state of every schedule
+func (r *MemoryRepository) GetSyncScheduleStates(ctx context.Context) ([]models.SyncScheduleState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]models.SyncScheduleState, 0, len(r.schedules))
+	for _, state := range r.schedules {
+		states = append(states, copyScheduleState(state))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+// GetSyncScheduleState gets the stored state of a schedule
+func (r *MemoryRepository) GetSyncScheduleState(ctx context.Context, name string) (*models.SyncScheduleState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.schedules[name]
+	if !ok {
+		return nil, nil
+	}
+	state = copyScheduleState(state)
+	return &state, nil
+}
+
+// SetSyncSchedulePaused pauses or resumes a schedule
+func (r *MemoryRepository) SetSyncSchedulePaused(ctx context.Context, name string, paused bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.schedules[name]
+	state.Name = name
+	state.Paused = paused
+	state.UpdatedAt = time.Now()
+	r.schedules[name] = state
+	return nil
+}
+
+// RecordSyncScheduleRun stores the outcome of a schedule's last run
+func (r *MemoryRepository) RecordSyncScheduleRun(ctx context.Context, run *models.SyncScheduleState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.schedules[run.Name]
+	state.Name = run.Name
+	state.LastRunAt = run.LastRunAt
+	state.LastJobID = run.LastJobID
+	state.LastError = run.LastError
+	state.UpdatedAt = time.Now()
+	r.schedules[run.Name] = copyScheduleState(state)
+	return nil
+}
+
+// GetSyncWatermark gets the watermark of an incremental sync source
+func (r *MemoryRepository) GetSyncWatermark(ctx context.Context, source string) (*models.SyncWatermark, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mark, ok := r.watermarks[source]
+	if !ok {
+		return nil, nil
+	}
+	return &mark, nil
+}
+
+// SetSyncWatermark moves the watermark of an incremental sync source
+func (r *MemoryRepository) SetSyncWatermark(ctx context.Context, source string, syncedUntil time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watermarks[source] = models.SyncWatermark{Source: source, SyncedUntil: syncedUntil, UpdatedAt: time.Now()}
+	return nil
+}
+
+// withGenres returns a copy of movie with its genres resolved from the
+// catalogue. Links to genres missing from the catalogue are skipped
+func (r *MemoryRepository) withGenres(movie *models.Movie) models.Movie {
+	result := *copyMovie(movie)
+	result.Genres = []models.Genre{}
+	for _, id := range r.movieGenres[movie.ID] {
+		if g, ok := r.genres[id]; ok {
+			result.Genres = append(result.Genres, g)
+		}
+	}
+	return result
+}
+
+// sortedMovieIDs returns the stored movie IDs in ascending order
+func (r *MemoryRepository) sortedMovieIDs() []uint {
+	ids := make([]uint, 0, len(r.movies))
+	for id := range r.movies {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// copySyncJob returns a copy of job that shares no pointers with it
+func copySyncJob(job *models.SyncJob) *models.SyncJob {
+	c := *job
+	if job.Report != nil {
+		report := *job.Report
+		report.Errors = append([]models.SyncError(nil), job.Report.Errors...)
+		c.Report = &report
+	}
+	if job.StartedAt != nil {
+		t := *job.StartedAt
+		c.StartedAt = &t
+	}
+	if job.FinishedAt != nil {
+		t := *job.FinishedAt
+		c.FinishedAt = &t
+	}
+	return &c
+}
+
+// copyScheduleState returns a copy of state that shares no pointers with it
+func copyScheduleState(state models.SyncScheduleState) models.SyncScheduleState {
+	if state.LastRunAt != nil {
+		t := *state.LastRunAt
+		state.LastRunAt = &t
+	}
+	if state.LastJobID != nil {
+		id := *state.LastJobID
+		state.LastJobID = &id
+	}
+	return state
+}
+
+// copyMovie returns a copy of movie that shares no slices with it
+func copyMovie(movie *models.Movie) *models.Movie {
+	c := *movie
+	if movie.Genres != nil {
+		c.Genres = append([]models.Genre(nil), movie.Genres...)
+	}
+	c.ManualFields = slices.Clone(movie.ManualFields)
+	c.LockedFields = slices.Clone(movie.LockedFields)
+	return &c
+}
+
+func releaseDateOf(c models.Credit) time.Time {
+	if c.Movie == nil {
+		return time.Time{}
+	}
+	return c.Movie.ReleaseDate
+}
+
+func containsAll(have, want []uint) bool {
+	for _, w := range want {
+		if !containsAny(have, []uint{w}) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(have, want []uint) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
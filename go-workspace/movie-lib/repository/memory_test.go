@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/repository/repositorytest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewMemoryRepository()
+	})
+}
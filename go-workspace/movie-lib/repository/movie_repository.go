@@ -1,59 +1,172 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/rohankarmacharya/movie-lib/config"
 	"github.com/rohankarmacharya/movie-lib/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// upsertColumns are the columns refreshed when a movie with the same external_id already exists
+var upsertColumns = []string{
+	"imdb_id", "title", "original_title", "original_language", "tagline", "description", "status",
+	"poster_path", "backdrop_path", "release_date", "runtime", "budget", "revenue", "rating", "vote_count", "popularity",
+	"updated_at",
+}
+
+// upsertAssignments refresh upsertColumns on conflict, except the columns of
+// the fields locked on the stored movie. Provenance and locks are kept, and
+// so are the detail columns a list payload leaves empty
+var upsertAssignments = func() clause.Set {
+	set := make(clause.Set, 0, len(upsertColumns))
+	for _, column := range upsertColumns {
+		incoming := "excluded." + column
+		if slices.Contains(models.DetailFields, column) {
+			zero := "''"
+			if column == "runtime" || column == "budget" || column == "revenue" {
+				zero = "0"
+			}
+			incoming = fmt.Sprintf("COALESCE(NULLIF(excluded.%s, %s), movies.%s)", column, zero, column)
+		}
+		value := clause.Expr{SQL: incoming}
+		if models.ValidMovieField(column) {
+			value = clause.Expr{
+				SQL:  fmt.Sprintf("CASE WHEN movies.locked_fields LIKE ? THEN movies.%s ELSE %s END", column, incoming),
+				Vars: []interface{}{`%"` + column + `"%`},
+			}
+		}
+		set = append(set, clause.Assignment{Column: clause.Column{Name: column}, Value: value})
+	}
+	return set
+}()
+
+// upsertByExternalID resolves conflicts on the partial unique index over
+// non-empty external_id, so manually created movies without one never collide
+func upsertByExternalID() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:     []clause.Column{{Name: "external_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "external_id <> ''"}}},
+		DoUpdates:   upsertAssignments,
+	}
+}
+
+// genreLockedExternalIDs returns which of the external IDs of movies belong
+// to stored movies with their genres locked, whose links an upsert keeps
+func genreLockedExternalIDs(tx *gorm.DB, movies []models.Movie) (map[string]bool, error) {
+	externalIDs := make([]string, 0, len(movies))
+	for _, m := range movies {
+		if m.ExternalID != "" {
+			externalIDs = append(externalIDs, m.ExternalID)
+		}
+	}
+	if len(externalIDs) == 0 {
+		return nil, nil
+	}
+
+	var stored []models.Movie
+	if err := tx.Select("external_id", "locked_fields").Where("external_id IN ?", externalIDs).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	locked := make(map[string]bool)
+	for _, m := range stored {
+		if slices.Contains(m.LockedFields, "genres") {
+			locked[m.ExternalID] = true
+		}
+	}
+	return locked, nil
+}
+
 // CreateMovie creates a new movie
-func CreateMovie(movie *models.Movie) error {
-	// Upsert by external_id: insert or update core fields on conflict
-	return config.DB.
-		Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "external_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"title", "description", "poster_path", "release_date", "rating", "updated_at"}),
-		}).
-		Create(movie).Error
+func (r *GormRepository) CreateMovie(ctx context.Context, movie *models.Movie) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Upsert by external_id: insert or update core fields on conflict
+		locked, err := genreLockedExternalIDs(tx, []models.Movie{*movie})
+		if err != nil {
+			return err
+		}
+		if err := tx.
+			Omit("Genres").
+			Clauses(upsertByExternalID()).
+			Create(movie).Error; err != nil {
+			return err
+		}
+		if locked[movie.ExternalID] {
+			return nil
+		}
+		return replaceMovieGenres(tx, movie)
+	})
 }
 
 // SaveMovies saves multiple movies to the database and returns the count of saved movies
-func SaveMovies(movies []models.Movie) (int64, error) {
-	// Bulk upsert by external_id
-	result := config.DB.
-		Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "external_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"title", "description", "poster_path", "release_date", "rating", "updated_at"}),
-		}).
-		Create(&movies)
-	return result.RowsAffected, result.Error
+func (r *GormRepository) SaveMovies(ctx context.Context, movies []models.Movie) (int64, error) {
+	if len(movies) == 0 {
+		return 0, nil
+	}
+
+	var saved int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := genreLockedExternalIDs(tx, movies)
+		if err != nil {
+			return err
+		}
+
+		// Bulk upsert by external_id
+		result := tx.
+			Omit("Genres").
+			Clauses(upsertByExternalID()).
+			Create(&movies)
+		if result.Error != nil {
+			return result.Error
+		}
+		saved = result.RowsAffected
+
+		for i := range movies {
+			if locked[movies[i].ExternalID] {
+				continue
+			}
+			if err := replaceMovieGenres(tx, &movies[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return saved, err
 }
 
 // GetMovieByID gets a movie by ID
-func GetMovieByID(id uint) (*models.Movie, error) {
+func (r *GormRepository) GetMovieByID(ctx context.Context, id uint) (*models.Movie, error) {
 	var movie models.Movie
-	err := config.DB.First(&movie, id).Error
+	err := r.db.WithContext(ctx).Preload("Genres").First(&movie, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &movie, nil
 }
 
-// UpdateMovie updates an existing movie
-func UpdateMovie(movie *models.Movie) error {
-	result := config.DB.Save(movie)
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
-	}
-	return result.Error
+// UpdateMovie updates an existing movie, keeping its creation time
+func (r *GormRepository) UpdateMovie(ctx context.Context, movie *models.Movie) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Save would insert a missing row, so check it exists first
+		var existing models.Movie
+		if err := tx.Select("id", "created_at").First(&existing, movie.ID).Error; err != nil {
+			return err
+		}
+		movie.CreatedAt = existing.CreatedAt
+		if err := tx.Omit("Genres").Save(movie).Error; err != nil {
+			return err
+		}
+		return replaceMovieGenres(tx, movie)
+	})
 }
 
 // DeleteMovie deletes a movie by ID
-func DeleteMovie(id uint) error {
-	result := config.DB.Delete(&models.Movie{}, id)
+func (r *GormRepository) DeleteMovie(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Movie{}, id)
 	if result.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
@@ -61,9 +174,26 @@ func DeleteMovie(id uint) error {
 }
 
 // GetAllMovies retrieves all movies from the database
-func GetAllMovies() ([]models.Movie, error) {
+func (r *GormRepository) GetAllMovies(ctx context.Context) ([]models.Movie, error) {
 	var movies []models.Movie
-	err := config.DB.Find(&movies).Error
+	err := r.db.WithContext(ctx).Preload("Genres").Find(&movies).Error
+	if err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// GetMoviesByExternalIDs retrieves the movies with the given external IDs
+func (r *GormRepository) GetMoviesByExternalIDs(ctx context.Context, externalIDs []string) ([]models.Movie, error) {
+	movies := []models.Movie{}
+	if len(externalIDs) == 0 {
+		return movies, nil
+	}
+	// Manually created movies share the empty external_id; never match them
+	err := r.db.WithContext(ctx).
+		Preload("Genres").
+		Where("external_id IN ? AND external_id <> ''", externalIDs).
+		Find(&movies).Error
 	if err != nil {
 		return nil, err
 	}
@@ -71,9 +201,9 @@ func GetAllMovies() ([]models.Movie, error) {
 }
 
 // GetMovieByTitleAndDate finds a movie by its title and release date
-func GetMovieByTitleAndDate(title string, releaseDate time.Time) (*models.Movie, error) {
+func (r *GormRepository) GetMovieByTitleAndDate(ctx context.Context, title string, releaseDate time.Time) (*models.Movie, error) {
 	var movie models.Movie
-	err := config.DB.Where("title = ? AND release_date = ?", title, releaseDate).First(&movie).Error
+	err := r.db.WithContext(ctx).Where("title = ? AND release_date = ?", title, releaseDate).First(&movie).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -84,18 +214,46 @@ func GetMovieByTitleAndDate(title string, releaseDate time.Time) (*models.Movie,
 }
 
 // GetMoviesWithPagination retrieves movies with filtering, searching, and pagination
-func GetMoviesWithPagination(params models.MovieQueryParams) (*models.PaginatedResponse, error) {
+func (r *GormRepository) GetMoviesWithPagination(ctx context.Context, params models.MovieQueryParams) (*models.PaginatedResponse, error) {
+	keys, err := params.SortKeys()
+	if err != nil {
+		return nil, err
+	}
+	facetNames, err := params.FacetNames()
+	if err != nil {
+		return nil, err
+	}
+	if params.Search == "" {
+		return r.listMovies(r.db.WithContext(ctx), params, keys, facetNames)
+	}
+
+	// The search condition may depend on settings of its transaction
+	var response *models.PaginatedResponse
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.dialect.prepareSearch(tx); err != nil {
+			return err
+		}
+		var err error
+		response, err = r.listMovies(tx, params, keys, facetNames)
+		return err
+	})
+	return response, err
+}
+
+// listMovies runs the queries of a movie listing on db
+func (r *GormRepository) listMovies(db *gorm.DB, params models.MovieQueryParams, keys []models.SortKey, facetNames []string) (*models.PaginatedResponse, error) {
 	var movies []models.Movie
-	var total int64
 
 	// Start building the query
-	query := config.DB.Model(&models.Movie{})
+	query := db.Model(&models.Movie{})
 
-	// Apply search (case-insensitive search in title and description)
+	// Apply search: full-text on Postgres, substrings of title and
+	// description elsewhere
+	var rank clause.Expr
 	if params.Search != "" {
-		searchTerm := "%" + params.Search + "%"
-		query = query.Where("LOWER(title) LIKE LOWER(?) OR LOWER(description) LIKE LOWER(?)",
-			searchTerm, searchTerm)
+		var cond clause.Expr
+		cond, rank = r.dialect.search(params.Search)
+		query = query.Where(cond)
 	}
 
 	// Apply rating filters
@@ -119,32 +277,212 @@ func GetMoviesWithPagination(params models.MovieQueryParams) (*models.PaginatedR
 		}
 	}
 
-	// Get total count for pagination
-	if err := query.Count(&total).Error; err != nil {
+	// Apply genre filters: genre requires every listed genre, genre_any at least one
+	if params.Genre != "" {
+		ids, unknown, err := resolveGenreIDs(db, params.Genre)
+		if err != nil {
+			return nil, err
+		}
+		if unknown {
+			query = query.Where("1 = 0")
+		} else if len(ids) > 0 {
+			query = query.Where("movies.id IN (?)", db.
+				Table("movie_genres").
+				Select("movie_id").
+				Where("genre_id IN ?", ids).
+				Group("movie_id").
+				Having("COUNT(DISTINCT genre_id) = ?", len(uniqueIDs(ids))))
+		}
+	}
+	if params.GenreAny != "" {
+		ids, _, err := resolveGenreIDs(db, params.GenreAny)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			query = query.Where("1 = 0")
+		} else {
+			query = query.Where("movies.id IN (?)", db.
+				Table("movie_genres").
+				Select("movie_id").
+				Where("genre_id IN ?", ids))
+		}
+	}
+
+	cursor, err := params.PageCursor(keys)
+	if err != nil {
 		return nil, err
 	}
 
-	// Calculate pagination
-	offset := (params.Page - 1) * params.Limit
-	totalPages := int((total + int64(params.Limit) - 1) / int64(params.Limit))
+	// Count the facets over the same matches
+	var facets map[string][]models.FacetCount
+	if len(facetNames) > 0 {
+		matches := query.Session(&gorm.Session{}).Select("movies.id")
+		if facets, err = r.countFacets(db, matches, facetNames); err != nil {
+			return nil, err
+		}
+	}
 
-	// Apply pagination and ordering
+	// Get total count for pagination, unless it isn't wanted
+	var total *int64
+	if params.CountsTotal() {
+		total = new(int64)
+		if err := query.Count(total).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Page by number, or by the keyset of the cursor's movie. One movie
+	// more than the limit tells whether another page follows
+	terms := sortTerms(keys, rank)
+	reverse := cursor != nil && cursor.Before
+	if cursor != nil {
+		query = query.Where(keysetCondition(terms, cursor))
+	} else {
+		query = query.Offset((params.Page - 1) * params.Limit)
+	}
 	if err := query.
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(params.Limit).
+		Preload("Genres").
+		Order(orderBy(terms, reverse)).
+		Limit(params.Limit + 1).
 		Find(&movies).Error; err != nil {
 		return nil, err
 	}
+	more := len(movies) > params.Limit
+	if more {
+		movies = movies[:params.Limit]
+	}
+	if reverse {
+		slices.Reverse(movies)
+	}
 
-	// Build the response
-	response := &models.PaginatedResponse{
-		Data:       movies,
-		Total:      total,
-		Page:       params.Page,
-		Limit:      params.Limit,
-		TotalPages: totalPages,
+	if params.Search != "" {
+		if err := r.setRanks(db, movies, rank); err != nil {
+			return nil, err
+		}
 	}
 
+	response := pageResponse(params, keys, cursor, movies, more, total)
+	response.Facets = facets
 	return response, nil
 }
+
+// SuggestMovies returns up to limit movies whose titles resemble query
+func (r *GormRepository) SuggestMovies(ctx context.Context, query string, limit int) ([]models.MovieSuggestion, error) {
+	return r.dialect.suggestTitles(r.db.WithContext(ctx), query, limit)
+}
+
+// sortTerm is an ORDER BY term of the movie listing
+type sortTerm struct {
+	field string
+	expr  clause.Expr
+	desc  bool
+}
+
+// sortTerms are the terms ordering by keys, relevance ranked by rank. Ties
+// are broken by id in the direction of the last key, so pages never overlap
+func sortTerms(keys []models.SortKey, rank clause.Expr) []sortTerm {
+	terms := make([]sortTerm, 0, len(keys)+1)
+	desc := false
+	for _, key := range keys {
+		term := sortTerm{field: key.Field, expr: clause.Expr{SQL: "movies." + key.Field}, desc: key.Desc}
+		if key.Field == models.SortRelevance {
+			term.expr, term.desc = rank, true
+		}
+		terms = append(terms, term)
+		desc = term.desc
+	}
+	return append(terms, sortTerm{field: "id", expr: clause.Expr{SQL: "movies.id"}, desc: desc})
+}
+
+// orderBy orders by terms, or against them when reverse is set
+func orderBy(terms []sortTerm, reverse bool) clause.OrderBy {
+	sql := make([]string, len(terms))
+	vars := make([]interface{}, len(terms))
+	for i, term := range terms {
+		sql[i] = "?" + direction(term.desc != reverse)
+		vars[i] = term.expr
+	}
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(sql, ", "), Vars: vars}}
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+// keysetCondition matches the movies after the cursor's movie in the order
+// of terms, or before it. The terms are compared one after another: a
+// movie follows if the first term that differs puts it after
+func keysetCondition(terms []sortTerm, cursor *models.Cursor) clause.Expr {
+	value := func(term sortTerm) interface{} {
+		if term.field == "id" {
+			return cursor.Pivot.ID
+		}
+		return cursor.Value(term.field)
+	}
+
+	var alternatives []string
+	var vars []interface{}
+	for i, term := range terms {
+		var conds []string
+		for _, equal := range terms[:i] {
+			conds = append(conds, "? = ?")
+			vars = append(vars, equal.expr, value(equal))
+		}
+		op := " > ?"
+		if term.desc != cursor.Before {
+			op = " < ?"
+		}
+		conds = append(conds, "?"+op)
+		vars = append(vars, term.expr, value(term))
+		alternatives = append(alternatives, "("+strings.Join(conds, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(alternatives, " OR ") + ")", Vars: vars}
+}
+
+// setRanks sets the search rank of movies
+func (r *GormRepository) setRanks(db *gorm.DB, movies []models.Movie, rank clause.Expr) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(movies))
+	for i, m := range movies {
+		ids[i] = m.ID
+	}
+
+	var ranks []struct {
+		ID   uint
+		Rank float64
+	}
+	if err := db.Model(&models.Movie{}).
+		Select("id, ? AS rank", rank).
+		Where("id IN ?", ids).
+		Scan(&ranks).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]float64, len(ranks))
+	for _, rk := range ranks {
+		byID[rk.ID] = rk.Rank
+	}
+	for i := range movies {
+		rank := byID[movies[i].ID]
+		movies[i].Rank = &rank
+	}
+	return nil
+}
+
+// uniqueIDs returns ids without duplicates
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,35 @@
+package repository
+
+import "github.com/rohankarmacharya/movie-lib/models"
+
+// pageResponse builds the response of a page of movies, read by page number
+// or from cursor. more reports whether movies continue past the page in the
+// direction it was read; total is nil when not counted
+func pageResponse(params models.MovieQueryParams, keys []models.SortKey, cursor *models.Cursor, movies []models.Movie, more bool, total *int64) *models.PaginatedResponse {
+	response := &models.PaginatedResponse{Data: movies, Limit: params.Limit}
+	if total != nil {
+		totalPages := int((*total + int64(params.Limit) - 1) / int64(params.Limit))
+		response.Total, response.TotalPages = total, &totalPages
+	}
+
+	var hasNext, hasPrev bool
+	switch {
+	case cursor == nil:
+		response.Page = params.Page
+		hasNext, hasPrev = more, params.Page > 1
+	case cursor.Before:
+		// The cursor's movie follows the page
+		hasNext, hasPrev = true, more
+	default:
+		hasNext, hasPrev = more, true
+	}
+	if len(movies) > 0 {
+		if hasNext {
+			response.NextCursor = models.EncodeCursor(keys, movies[len(movies)-1], false)
+		}
+		if hasPrev {
+			response.PrevCursor = models.EncodeCursor(keys, movies[0], true)
+		}
+	}
+	return response
+}
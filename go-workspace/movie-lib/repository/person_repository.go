@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveMovieCredits replaces the credits of a movie, upserting the people they
+// refer to by external_id
+func (r *GormRepository) SaveMovieCredits(ctx context.Context, movieID uint, credits []models.Credit) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Upsert each person once, even if they appear in both cast and crew
+		people := make(map[string]*models.Person)
+		var unique []*models.Person
+		for _, c := range credits {
+			if c.Person == nil {
+				continue
+			}
+			if _, ok := people[c.Person.ExternalID]; !ok {
+				p := *c.Person
+				people[p.ExternalID] = &p
+				unique = append(unique, &p)
+			}
+		}
+
+		if len(unique) > 0 {
+			if err := tx.
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "external_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"name", "profile_path", "known_for_department", "updated_at"}),
+				}).
+				Create(&unique).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("movie_id = ?", movieID).Delete(&models.Credit{}).Error; err != nil {
+			return err
+		}
+
+		rows := make([]models.Credit, 0, len(credits))
+		for _, c := range credits {
+			if c.Person != nil {
+				c.PersonID = people[c.Person.ExternalID].ID
+			}
+			c.ID = 0
+			c.MovieID = movieID
+			c.Person = nil
+			c.Movie = nil
+			rows = append(rows, c)
+		}
+		if len(rows) > 0 {
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		// The cast is part of the movie's search document
+		return r.dialect.refreshSearch(tx, movieID)
+	})
+}
+
+// GetMovieCredits retrieves the cast and crew of a movie, in billing order
+func (r *GormRepository) GetMovieCredits(ctx context.Context, movieID uint) (*models.MovieCredits, error) {
+	var credits []models.Credit
+	err := r.db.WithContext(ctx).
+		Preload("Person").
+		Where("movie_id = ?", movieID).
+		Order("credit_order, id").
+		Find(&credits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.MovieCredits{
+		MovieID: movieID,
+		Cast:    []models.Credit{},
+		Crew:    []models.Credit{},
+	}
+	for _, c := range credits {
+		if c.Role == models.CreditRoleCast {
+			result.Cast = append(result.Cast, c)
+		} else {
+			result.Crew = append(result.Crew, c)
+		}
+	}
+	return result, nil
+}
+
+// GetPersonByID gets a person by ID
+func (r *GormRepository) GetPersonByID(ctx context.Context, id uint) (*models.Person, error) {
+	var person models.Person
+	if err := r.db.WithContext(ctx).First(&person, id).Error; err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+// GetPersonCredits retrieves every credit of a person with the movie attached,
+// newest release first
+func (r *GormRepository) GetPersonCredits(ctx context.Context, personID uint) ([]models.Credit, error) {
+	var credits []models.Credit
+	err := r.db.WithContext(ctx).
+		Joins("Movie").
+		Where("credits.person_id = ?", personID).
+		Order(`"Movie"."release_date" DESC, credits.id`).
+		Find(&credits).Error
+	if err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by every implementation when a record doesn't exist.
+// It is gorm.ErrRecordNotFound so callers can keep comparing against either
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrSyncJobActive is returned when creating a sync job while another job for
+// the same source is still queued or running
+var ErrSyncJobActive = errors.New("a sync job for this source is already queued or running")
+
+// MovieRepository stores movies
+type MovieRepository interface {
+	// CreateMovie inserts a movie, or updates the existing movie with the same external_id
+	CreateMovie(ctx context.Context, movie *models.Movie) error
+	// SaveMovies upserts movies by external_id and returns how many rows were written
+	SaveMovies(ctx context.Context, movies []models.Movie) (int64, error)
+	GetMovieByID(ctx context.Context, id uint) (*models.Movie, error)
+	// UpdateMovie replaces a stored movie, failing with ErrNotFound if it doesn't exist
+	UpdateMovie(ctx context.Context, movie *models.Movie) error
+	// DeleteMovie deletes a movie, failing with ErrNotFound if it doesn't exist
+	DeleteMovie(ctx context.Context, id uint) error
+	GetAllMovies(ctx context.Context) ([]models.Movie, error)
+	// GetMoviesByExternalIDs returns the movies with the given external IDs,
+	// genres included; IDs without a movie are left out
+	GetMoviesByExternalIDs(ctx context.Context, externalIDs []string) ([]models.Movie, error)
+	// GetMovieByTitleAndDate returns nil without error if no movie matches
+	GetMovieByTitleAndDate(ctx context.Context, title string, releaseDate time.Time) (*models.Movie, error)
+	GetMoviesWithPagination(ctx context.Context, params models.MovieQueryParams) (*models.PaginatedResponse, error)
+	// SuggestMovies returns up to limit movies whose titles resemble query,
+	// forgiving typos and accents, best match first
+	SuggestMovies(ctx context.Context, query string, limit int) ([]models.MovieSuggestion, error)
+}
+
+// GenreRepository stores the genre catalogue
+type GenreRepository interface {
+	SaveGenres(ctx context.Context, genres []models.Genre) error
+	GetAllGenres(ctx context.Context) ([]models.Genre, error)
+}
+
+// PersonRepository stores people and their movie credits
+type PersonRepository interface {
+	SaveMovieCredits(ctx context.Context, movieID uint, credits []models.Credit) error
+	GetMovieCredits(ctx context.Context, movieID uint) (*models.MovieCredits, error)
+	GetPersonByID(ctx context.Context, id uint) (*models.Person, error)
+	GetPersonCredits(ctx context.Context, personID uint) ([]models.Credit, error)
+}
+
+// SyncJobRepository stores background sync jobs and their history
+type SyncJobRepository interface {
+	// CreateSyncJob stores a new job, failing with ErrSyncJobActive if the
+	// source already has an active one
+	CreateSyncJob(ctx context.Context, job *models.SyncJob) error
+	UpdateSyncJob(ctx context.Context, job *models.SyncJob) error
+	// TouchSyncJob bumps the updated_at of an active job, showing that its
+	// runner is still alive. It does nothing to a finished job
+	TouchSyncJob(ctx context.Context, id uint) error
+	GetSyncJob(ctx context.Context, id uint) (*models.SyncJob, error)
+	// GetActiveSyncJob returns nil without error if the source has no active job
+	GetActiveSyncJob(ctx context.Context, source string) (*models.SyncJob, error)
+	// ListSyncJobs returns the most recent jobs first
+	ListSyncJobs(ctx context.Context, limit int) ([]models.SyncJob, error)
+	// FailActiveSyncJobs marks every queued or running job last updated
+	// before the given time as failed with message, and returns how many it marked
+	FailActiveSyncJobs(ctx context.Context, updatedBefore time.Time, message string) (int64, error)
+}
+
+// SyncScheduleRepository stores the shared state of sync schedules. A
+// schedule without stored state is active and has never run
+type SyncScheduleRepository interface {
+	GetSyncScheduleStates(ctx context.Context) ([]models.SyncScheduleState, error)
+	// GetSyncScheduleState returns nil without error if name has no stored state
+	GetSyncScheduleState(ctx context.Context, name string) (*models.SyncScheduleState, error)
+	SetSyncSchedulePaused(ctx context.Context, name string, paused bool) error
+	// RecordSyncScheduleRun stores the LastRunAt, LastJobID and LastError of
+	// state, leaving Paused alone
+	RecordSyncScheduleRun(ctx context.Context, state *models.SyncScheduleState) error
+}
+
+// SyncWatermarkRepository stores how far incremental syncs have caught up
+type SyncWatermarkRepository interface {
+	// GetSyncWatermark returns nil without error if source has no watermark yet
+	GetSyncWatermark(ctx context.Context, source string) (*models.SyncWatermark, error)
+	SetSyncWatermark(ctx context.Context, source string, syncedUntil time.Time) error
+}
+
+// Repository is the complete storage used by the service and handlers
+type Repository interface {
+	MovieRepository
+	GenreRepository
+	PersonRepository
+	SyncJobRepository
+	SyncScheduleRepository
+	SyncWatermarkRepository
+
+	// Transaction runs fn against a repository whose writes are kept only if
+	// fn returns nil. Transactions may be nested
+	Transaction(ctx context.Context, fn func(tx Repository) error) error
+}
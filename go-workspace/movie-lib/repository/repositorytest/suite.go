@@ -0,0 +1,1144 @@
+// Package repositorytest is the conformance suite every repository.Repository
+// implementation must pass. Call Run from the implementation's tests
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+)
+
+// Factory returns a new, empty repository. It is called once per subtest
+type Factory func(t *testing.T) repository.Repository
+
+// Run runs the conformance suite against the repositories made by newRepo
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r repository.Repository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateUpsertsByExternalID", testCreateUpsertsByExternalID},
+		{"UpsertKeepsLockedFields", testUpsertKeepsLockedFields},
+		{"UpsertKeepsDetails", testUpsertKeepsDetails},
+		{"EmptyExternalIDsDoNotCollide", testEmptyExternalIDs},
+		{"SaveMovies", testSaveMovies},
+		{"UpdateMovie", testUpdateMovie},
+		{"DeleteMovie", testDeleteMovie},
+		{"GetMovieByTitleAndDate", testGetMovieByTitleAndDate},
+		{"Pagination", testPagination},
+		{"Filters", testFilters},
+		{"SearchRelevance", testSearchRelevance},
+		{"Sort", testSort},
+		{"CursorPagination", testCursorPagination},
+		{"Facets", testFacets},
+		{"SuggestMovies", testSuggestMovies},
+		{"Genres", testGenres},
+		{"Credits", testCredits},
+		{"GetMoviesByExternalIDs", testGetMoviesByExternalIDs},
+		{"Transaction", testTransaction},
+		{"CanceledContext", testCanceledContext},
+		{"SyncJobs", testSyncJobs},
+		{"FailActiveSyncJobs", testFailActiveSyncJobs},
+		{"SyncSchedules", testSyncSchedules},
+		{"SyncWatermarks", testSyncWatermarks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+var (
+	drama  = models.Genre{ID: 18, Name: "Drama"}
+	crime  = models.Genre{ID: 80, Name: "Crime"}
+	comedy = models.Genre{ID: 35, Name: "Comedy"}
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func mustCreate(t *testing.T, r repository.Repository, m models.Movie) models.Movie {
+	t.Helper()
+	if err := r.CreateMovie(t.Context(), &m); err != nil {
+		t.Fatalf("CreateMovie(%q): %v", m.Title, err)
+	}
+	if m.ID == 0 {
+		t.Fatalf("CreateMovie(%q) left ID unset", m.Title)
+	}
+	return m
+}
+
+func mustGet(t *testing.T, r repository.Repository, id uint) *models.Movie {
+	t.Helper()
+	m, err := r.GetMovieByID(t.Context(), id)
+	if err != nil {
+		t.Fatalf("GetMovieByID(%d): %v", id, err)
+	}
+	return m
+}
+
+func genreIDs(genres []models.Genre) []uint {
+	ids := make([]uint, 0, len(genres))
+	for _, g := range genres {
+		ids = append(ids, g.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateAndGet(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, crime}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	created := mustCreate(t, r, models.Movie{
+		ExternalID:  "238",
+		Title:       "The Godfather",
+		Description: "Crime family saga",
+		ReleaseDate: date("1972-03-14"),
+		Runtime:     175,
+		Rating:      8.7,
+		Genres:      []models.Genre{crime, drama},
+	})
+
+	got := mustGet(t, r, created.ID)
+	if got.Title != "The Godfather" || got.ExternalID != "238" || got.Runtime != 175 || got.Rating != 8.7 {
+		t.Errorf("GetMovieByID = %+v, want the created movie", got)
+	}
+	if !got.ReleaseDate.Equal(date("1972-03-14")) {
+		t.Errorf("ReleaseDate = %v, want 1972-03-14", got.ReleaseDate)
+	}
+	if ids := genreIDs(got.Genres); !equalIDs(ids, []uint{18, 80}) {
+		t.Errorf("genres = %v, want [18 80]", ids)
+	}
+
+	if _, err := r.GetMovieByID(t.Context(), created.ID+100); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetMovieByID(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testCreateUpsertsByExternalID(t *testing.T, r repository.Repository) {
+	first := mustCreate(t, r, models.Movie{ExternalID: "550", Title: "Fight Club", ReleaseDate: date("1999-10-15")})
+	second := mustCreate(t, r, models.Movie{ExternalID: "550", Title: "Fight Club (remastered)", ReleaseDate: date("1999-10-15")})
+	if second.ID != first.ID {
+		t.Errorf("second create got ID %d, want existing ID %d", second.ID, first.ID)
+	}
+
+	all, err := r.GetAllMovies(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllMovies: %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("GetAllMovies returned %d movies, want 1", len(all))
+	}
+	if all[0].Title != "Fight Club (remastered)" {
+		t.Errorf("title = %q, want the upserted title", all[0].Title)
+	}
+}
+
+func testUpsertKeepsLockedFields(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, crime, comedy}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	first := mustCreate(t, r, models.Movie{
+		ExternalID:   "550",
+		Title:        "Fight Club (Director's Cut)",
+		Description:  "An insomniac office worker",
+		ReleaseDate:  date("1999-10-15"),
+		Genres:       []models.Genre{drama},
+		ManualFields: []string{"title"},
+		LockedFields: []string{"title", "genres"},
+	})
+	mustCreate(t, r, models.Movie{
+		ExternalID:  "550",
+		Title:       "Fight Club",
+		Description: "A ticking-time-bomb insomniac",
+		ReleaseDate: date("1999-10-15"),
+		Genres:      []models.Genre{comedy},
+	})
+
+	got := mustGet(t, r, first.ID)
+	if got.Title != "Fight Club (Director's Cut)" {
+		t.Errorf("locked title = %q, want it kept", got.Title)
+	}
+	if got.Description != "A ticking-time-bomb insomniac" {
+		t.Errorf("description = %q, want the upserted one", got.Description)
+	}
+	if ids := genreIDs(got.Genres); !equalIDs(ids, []uint{18}) {
+		t.Errorf("locked genres = %v, want [18]", ids)
+	}
+	if !slices.Equal(got.ManualFields, []string{"title"}) || !slices.Equal(got.LockedFields, []string{"title", "genres"}) {
+		t.Errorf("provenance = %v, locks = %v; want them kept", got.ManualFields, got.LockedFields)
+	}
+
+	// Updates store the locks given, including none
+	got.LockedFields = nil
+	if err := r.UpdateMovie(t.Context(), got); err != nil {
+		t.Fatalf("UpdateMovie: %v", err)
+	}
+	if got = mustGet(t, r, first.ID); len(got.LockedFields) != 0 {
+		t.Errorf("locks after unlocking = %v, want none", got.LockedFields)
+	}
+}
+
+func testUpsertKeepsDetails(t *testing.T, r repository.Repository) {
+	first := mustCreate(t, r, models.Movie{
+		ExternalID:  "278",
+		IMDbID:      "tt0111161",
+		Title:       "The Shawshank Redemption",
+		Tagline:     "Fear can hold you prisoner. Hope can set you free.",
+		Status:      "Released",
+		ReleaseDate: date("1994-09-23"),
+		Runtime:     142,
+		Budget:      25000000,
+		Revenue:     28341469,
+		Rating:      8.6,
+	})
+	// List payloads carry no details
+	if _, err := r.SaveMovies(t.Context(), []models.Movie{{ExternalID: "278", Title: "The Shawshank Redemption", ReleaseDate: date("1994-09-23"), Rating: 8.7}}); err != nil {
+		t.Fatalf("SaveMovies: %v", err)
+	}
+
+	got := mustGet(t, r, first.ID)
+	if got.Rating != 8.7 {
+		t.Errorf("rating = %v, want the upserted 8.7", got.Rating)
+	}
+	if got.IMDbID != first.IMDbID || got.Tagline != first.Tagline || got.Status != first.Status ||
+		got.Runtime != first.Runtime || got.Budget != first.Budget || got.Revenue != first.Revenue {
+		t.Errorf("details = %q %q %q %d %d %d, want them kept", got.IMDbID, got.Tagline, got.Status, got.Runtime, got.Budget, got.Revenue)
+	}
+}
+
+func testEmptyExternalIDs(t *testing.T, r repository.Repository) {
+	a := mustCreate(t, r, models.Movie{Title: "Home Movie", ReleaseDate: date("2020-01-01")})
+	b := mustCreate(t, r, models.Movie{Title: "Another Home Movie", ReleaseDate: date("2021-01-01")})
+	if a.ID == b.ID {
+		t.Fatalf("movies without external_id share ID %d", a.ID)
+	}
+	all, err := r.GetAllMovies(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllMovies: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("GetAllMovies returned %d movies, want 2", len(all))
+	}
+}
+
+func testSaveMovies(t *testing.T, r repository.Repository) {
+	if n, err := r.SaveMovies(t.Context(), nil); err != nil || n != 0 {
+		t.Errorf("SaveMovies(nil) = %d, %v; want 0, nil", n, err)
+	}
+
+	movies := []models.Movie{
+		{ExternalID: "13", Title: "Forrest Gump", ReleaseDate: date("1994-06-23")},
+		{ExternalID: "603", Title: "The Matrix", ReleaseDate: date("1999-03-31")},
+	}
+	n, err := r.SaveMovies(t.Context(), movies)
+	if err != nil {
+		t.Fatalf("SaveMovies: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("SaveMovies saved %d, want 2", n)
+	}
+	for _, m := range movies {
+		if m.ID == 0 {
+			t.Errorf("SaveMovies left ID of %q unset", m.Title)
+		}
+	}
+
+	// Saving again updates in place
+	movies = []models.Movie{{ExternalID: "603", Title: "The Matrix (1999)", ReleaseDate: date("1999-03-31")}}
+	if _, err := r.SaveMovies(t.Context(), movies); err != nil {
+		t.Fatalf("SaveMovies again: %v", err)
+	}
+	all, err := r.GetAllMovies(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllMovies: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("GetAllMovies returned %d movies after re-save, want 2", len(all))
+	}
+}
+
+func testUpdateMovie(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, comedy}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	m := mustCreate(t, r, models.Movie{Title: "Draft", ReleaseDate: date("2001-04-25"), Genres: []models.Genre{drama}})
+	createdAt := mustGet(t, r, m.ID).CreatedAt
+
+	update := models.Movie{ID: m.ID, Title: "Amélie", ReleaseDate: date("2001-04-25"), Rating: 7.9, Genres: []models.Genre{comedy}}
+	if err := r.UpdateMovie(t.Context(), &update); err != nil {
+		t.Fatalf("UpdateMovie: %v", err)
+	}
+	got := mustGet(t, r, m.ID)
+	if got.Title != "Amélie" || got.Rating != 7.9 {
+		t.Errorf("after update got %+v", got)
+	}
+	if !got.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt changed from %v to %v", createdAt, got.CreatedAt)
+	}
+	if ids := genreIDs(got.Genres); !equalIDs(ids, []uint{35}) {
+		t.Errorf("genres = %v, want [35]", ids)
+	}
+
+	missing := models.Movie{ID: m.ID + 100, Title: "Ghost"}
+	if err := r.UpdateMovie(t.Context(), &missing); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateMovie(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.GetMovieByID(t.Context(), m.ID+100); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateMovie(missing) created a movie")
+	}
+}
+
+func testDeleteMovie(t *testing.T, r repository.Repository) {
+	m := mustCreate(t, r, models.Movie{Title: "Doomed", ReleaseDate: date("2000-01-01")})
+	if err := r.DeleteMovie(t.Context(), m.ID); err != nil {
+		t.Fatalf("DeleteMovie: %v", err)
+	}
+	if _, err := r.GetMovieByID(t.Context(), m.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetMovieByID after delete error = %v, want ErrNotFound", err)
+	}
+	if err := r.DeleteMovie(t.Context(), m.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("DeleteMovie(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testGetMovieByTitleAndDate(t *testing.T, r repository.Repository) {
+	m := mustCreate(t, r, models.Movie{Title: "Pulp Fiction", ReleaseDate: date("1994-09-10")})
+
+	got, err := r.GetMovieByTitleAndDate(t.Context(), "Pulp Fiction", date("1994-09-10"))
+	if err != nil {
+		t.Fatalf("GetMovieByTitleAndDate: %v", err)
+	}
+	if got == nil || got.ID != m.ID {
+		t.Errorf("GetMovieByTitleAndDate = %+v, want movie %d", got, m.ID)
+	}
+
+	got, err = r.GetMovieByTitleAndDate(t.Context(), "Pulp Fiction", date("1994-09-11"))
+	if err != nil || got != nil {
+		t.Errorf("GetMovieByTitleAndDate(other date) = %+v, %v; want nil, nil", got, err)
+	}
+}
+
+func titles(t *testing.T, resp *models.PaginatedResponse) []string {
+	t.Helper()
+	movies, ok := resp.Data.([]models.Movie)
+	if !ok {
+		t.Fatalf("Data is %T, want []models.Movie", resp.Data)
+	}
+	names := make([]string, 0, len(movies))
+	for _, m := range movies {
+		names = append(names, m.Title)
+	}
+	return names
+}
+
+// deref returns *p, or nil for a nil p
+func deref[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testPagination(t *testing.T, r repository.Repository) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"A", "B", "C", "D", "E"} {
+		mustCreate(t, r, models.Movie{
+			Title:       title,
+			ReleaseDate: date("2000-01-01"),
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	resp, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetMoviesWithPagination: %v", err)
+	}
+	if resp.Total == nil || *resp.Total != 5 || resp.TotalPages == nil || *resp.TotalPages != 3 || resp.Page != 2 || resp.Limit != 2 {
+		t.Errorf("got total=%v pages=%v page=%d limit=%d, want 5, 3, 2, 2",
+			deref(resp.Total), deref(resp.TotalPages), resp.Page, resp.Limit)
+	}
+	// Newest first
+	if got := titles(t, resp); !equalStrings(got, []string{"C", "B"}) {
+		t.Errorf("page 2 = %v, want [C B]", got)
+	}
+
+	resp, err = r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 4, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetMoviesWithPagination past the end: %v", err)
+	}
+	if got := titles(t, resp); len(got) != 0 {
+		t.Errorf("page past the end = %v, want none", got)
+	}
+}
+
+func testSearchRelevance(t *testing.T, r repository.Repository) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []models.Movie{
+		{Title: "Crime Story", Description: "Chicago detectives", ReleaseDate: date("1986-09-18")},
+		{Title: "Pulp Fiction", Description: "Crime stories intertwine", ReleaseDate: date("1994-09-10")},
+		{Title: "Amélie", Description: "A shy waitress in Paris", ReleaseDate: date("2001-04-25")},
+	}
+	for i, m := range fixtures {
+		m.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		mustCreate(t, r, m)
+	}
+
+	resp, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10, Search: "crime", Sort: models.SortRelevance})
+	if err != nil {
+		t.Fatalf("GetMoviesWithPagination: %v", err)
+	}
+	if got := titles(t, resp); !equalStrings(got, []string{"Crime Story", "Pulp Fiction"}) {
+		t.Fatalf("relevance order = %v, want the title match first", got)
+	}
+	movies := resp.Data.([]models.Movie)
+	if movies[0].Rank == nil || movies[1].Rank == nil || *movies[0].Rank <= *movies[1].Rank {
+		t.Errorf("ranks = %v, %v; want both set, descending", movies[0].Rank, movies[1].Rank)
+	}
+
+	// Without sort=relevance hits keep the default order
+	resp, err = r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10, Search: "crime"})
+	if err != nil {
+		t.Fatalf("GetMoviesWithPagination: %v", err)
+	}
+	if got := titles(t, resp); !equalStrings(got, []string{"Pulp Fiction", "Crime Story"}) {
+		t.Errorf("default order = %v, want newest first", got)
+	}
+}
+
+func testSort(t *testing.T, r repository.Repository) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []models.Movie{
+		{Title: "Forrest Gump", ReleaseDate: date("1994-06-23"), Rating: 8.5, Popularity: 92.4},
+		{Title: "Amélie", ReleaseDate: date("2001-04-25"), Rating: 7.9, Popularity: 45.3},
+		{Title: "Pulp Fiction", ReleaseDate: date("1994-09-10"), Rating: 8.5, Popularity: 88.9},
+		{Title: "The Godfather", ReleaseDate: date("1972-03-14"), Rating: 8.7, Popularity: 120.5},
+		{Title: "Fight Club", ReleaseDate: date("1999-10-15"), Rating: 8.5, Popularity: 98.7},
+	}
+	for i, m := range fixtures {
+		m.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		mustCreate(t, r, m)
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"Fight Club", "The Godfather", "Pulp Fiction", "Amélie", "Forrest Gump"}},
+		{"title", []string{"Amélie", "Fight Club", "Forrest Gump", "Pulp Fiction", "The Godfather"}},
+		{"-release_date", []string{"Amélie", "Fight Club", "Pulp Fiction", "Forrest Gump", "The Godfather"}},
+		{"-popularity", []string{"The Godfather", "Fight Club", "Forrest Gump", "Pulp Fiction", "Amélie"}},
+		{"-rating,title", []string{"The Godfather", "Fight Club", "Forrest Gump", "Pulp Fiction", "Amélie"}},
+		{"rating, -title", []string{"Amélie", "Pulp Fiction", "Forrest Gump", "Fight Club", "The Godfather"}},
+		// Ties fall back to id, in the direction of the last key
+		{"-rating", []string{"The Godfather", "Fight Club", "Pulp Fiction", "Forrest Gump", "Amélie"}},
+		{"rating", []string{"Amélie", "Forrest Gump", "Pulp Fiction", "Fight Club", "The Godfather"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			resp, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10, Sort: tt.sort})
+			if err != nil {
+				t.Fatalf("GetMoviesWithPagination: %v", err)
+			}
+			if got := titles(t, resp); !equalStrings(got, tt.want) {
+				t.Errorf("sort=%s gives %v, want %v", tt.sort, got, tt.want)
+			}
+
+			// Paging one movie at a time gives the same order
+			var paged []string
+			for page := 1; page <= len(tt.want); page++ {
+				resp, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: page, Limit: 1, Sort: tt.sort})
+				if err != nil {
+					t.Fatalf("GetMoviesWithPagination: %v", err)
+				}
+				paged = append(paged, titles(t, resp)...)
+			}
+			if !equalStrings(paged, tt.want) {
+				t.Errorf("sort=%s pages give %v, want %v", tt.sort, paged, tt.want)
+			}
+		})
+	}
+
+	for _, sort := range []string{"budget", "-relevance", "title,-title", "relevance"} {
+		if _, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10, Sort: sort}); err == nil {
+			t.Errorf("sort=%s: want an error", sort)
+		}
+	}
+}
+
+func testCursorPagination(t *testing.T, r repository.Repository) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []models.Movie{
+		{Title: "Forrest Gump", Description: "A box of chocolates", Rating: 8.5, Popularity: 92.4},
+		{Title: "Amélie", Description: "A shy waitress", Rating: 7.9, Popularity: 45.3},
+		{Title: "Pulp Fiction", Description: "Crime stories intertwine", Rating: 8.5, Popularity: 88.9},
+		{Title: "The Godfather", Description: "A crime family", Rating: 8.7, Popularity: 120.5},
+		{Title: "Fight Club", Description: "Soap and mischief", Rating: 8.5, Popularity: 98.7},
+		{Title: "Crime Story", Description: "Chicago detectives", Rating: 7.0, Popularity: 12.0},
+	}
+	for i, m := range fixtures {
+		m.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		mustCreate(t, r, m)
+	}
+
+	list := func(t *testing.T, params models.MovieQueryParams) *models.PaginatedResponse {
+		t.Helper()
+		resp, err := r.GetMoviesWithPagination(t.Context(), params)
+		if err != nil {
+			t.Fatalf("GetMoviesWithPagination(%+v): %v", params, err)
+		}
+		return resp
+	}
+
+	for _, params := range []models.MovieQueryParams{
+		{},
+		{Sort: "-rating,title"},
+		{Sort: "rating"},
+		{Sort: "-popularity"},
+		{Search: "crime", Sort: "relevance"},
+	} {
+		t.Run(params.Sort, func(t *testing.T) {
+			all := params
+			all.Page, all.Limit = 1, 10
+			want := titles(t, list(t, all))
+
+			// Forward from the first page by next_cursor
+			params.Page, params.Limit = 1, 2
+			resp := list(t, params)
+			if resp.PrevCursor != "" {
+				t.Errorf("first page has prev_cursor %q", resp.PrevCursor)
+			}
+			got := titles(t, resp)
+			for resp.NextCursor != "" {
+				params.Cursor = resp.NextCursor
+				resp = list(t, params)
+				got = append(got, titles(t, resp)...)
+			}
+			if !equalStrings(got, want) {
+				t.Fatalf("forward pages = %v, want %v", got, want)
+			}
+
+			// Back from the last page by prev_cursor
+			got = titles(t, resp)
+			for resp.PrevCursor != "" {
+				params.Cursor = resp.PrevCursor
+				resp = list(t, params)
+				got = append(titles(t, resp), got...)
+			}
+			if !equalStrings(got, want) {
+				t.Errorf("backward pages = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("insert while browsing", func(t *testing.T) {
+		resp := list(t, models.MovieQueryParams{Page: 1, Limit: 3})
+		first := titles(t, resp)
+		mustCreate(t, r, models.Movie{Title: "Newcomer", CreatedAt: base.Add(24 * time.Hour)})
+		resp = list(t, models.MovieQueryParams{Limit: 3, Cursor: resp.NextCursor})
+		if got := append(first, titles(t, resp)...); !equalStrings(got, []string{"Crime Story", "Fight Club", "The Godfather", "Pulp Fiction", "Amélie", "Forrest Gump"}) {
+			t.Errorf("pages = %v, want the original movies once each", got)
+		}
+	})
+
+	t.Run("without total", func(t *testing.T) {
+		resp := list(t, models.MovieQueryParams{Page: 1, Limit: 2, IncludeTotal: new(bool)})
+		if resp.Total != nil || resp.TotalPages != nil {
+			t.Errorf("total = %v, pages = %v; want neither", deref(resp.Total), deref(resp.TotalPages))
+		}
+		if resp.NextCursor == "" {
+			t.Error("next_cursor missing")
+		}
+	})
+
+	t.Run("invalid cursors", func(t *testing.T) {
+		resp := list(t, models.MovieQueryParams{Page: 1, Limit: 2})
+		for _, params := range []models.MovieQueryParams{
+			{Limit: 2, Cursor: "not a cursor"},
+			{Limit: 2, Cursor: resp.NextCursor, Sort: "title"},
+		} {
+			if _, err := r.GetMoviesWithPagination(t.Context(), params); !errors.Is(err, models.ErrInvalidCursor) {
+				t.Errorf("cursor %q with sort=%q: err = %v, want ErrInvalidCursor", params.Cursor, params.Sort, err)
+			}
+		}
+	})
+}
+
+func testFacets(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, crime, comedy}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	for _, m := range []models.Movie{
+		{Title: "The Godfather", ReleaseDate: date("1972-03-14"), Rating: 8.7, OriginalLanguage: "en", Genres: []models.Genre{drama, crime}},
+		{Title: "Amélie", ReleaseDate: date("2001-04-25"), Rating: 7.9, OriginalLanguage: "fr", Genres: []models.Genre{comedy}},
+		{Title: "Forrest Gump", ReleaseDate: date("1994-06-23"), Rating: 8.5, OriginalLanguage: "en", Genres: []models.Genre{comedy, drama}},
+		{Title: "Pulp Fiction", ReleaseDate: date("1994-09-10"), Rating: 8.5, OriginalLanguage: "en", Genres: []models.Genre{crime}},
+		{Title: "Untitled", Rating: 10},
+	} {
+		mustCreate(t, r, m)
+	}
+
+	// facets formats a facet as "value:count", or "name:count" for genres
+	facets := func(resp *models.PaginatedResponse, name string) []string {
+		var values []string
+		for _, v := range resp.Facets[name] {
+			label := v.Value
+			if v.Name != "" {
+				label = v.Name
+			}
+			values = append(values, fmt.Sprintf("%s:%d", label, v.Count))
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		params models.MovieQueryParams
+		want   map[string][]string
+	}{
+		{"every movie", models.MovieQueryParams{Facets: "genre,year,decade,rating,language"}, map[string][]string{
+			models.FacetGenre:    {"Comedy:2", "Crime:2", "Drama:2"},
+			models.FacetYear:     {"2001:1", "1994:2", "1972:1"},
+			models.FacetDecade:   {"2000:1", "1990:2", "1970:1"},
+			models.FacetRating:   {"9:1", "8:3", "7:1"},
+			models.FacetLanguage: {"en:3", "fr:1"},
+		}},
+		{"filtered", models.MovieQueryParams{Genre: "crime", Facets: "genre,year,language"}, map[string][]string{
+			models.FacetGenre:    {"Crime:2", "Drama:1"},
+			models.FacetYear:     {"1994:1", "1972:1"},
+			models.FacetLanguage: {"en:2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Page, tt.params.Limit = 1, 1
+			resp, err := r.GetMoviesWithPagination(t.Context(), tt.params)
+			if err != nil {
+				t.Fatalf("GetMoviesWithPagination: %v", err)
+			}
+			if len(resp.Facets) != len(tt.want) {
+				t.Errorf("facets %v, want %d of them", resp.Facets, len(tt.want))
+			}
+			for name, want := range tt.want {
+				if got := facets(resp, name); !equalStrings(got, want) {
+					t.Errorf("facet %s = %v, want %v", name, got, want)
+				}
+			}
+			if got := titles(t, resp); len(got) != 1 {
+				t.Errorf("page = %v, want the limit of 1 movie", got)
+			}
+		})
+	}
+
+	if _, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10, Facets: "budget"}); err == nil {
+		t.Error("facets=budget: want an error")
+	}
+	resp, err := r.GetMoviesWithPagination(t.Context(), models.MovieQueryParams{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetMoviesWithPagination: %v", err)
+	}
+	if resp.Facets != nil {
+		t.Errorf("facets = %v without asking for any", resp.Facets)
+	}
+}
+
+func testSuggestMovies(t *testing.T, r repository.Repository) {
+	for _, m := range []models.Movie{
+		{Title: "The Godfather", ReleaseDate: date("1972-03-14"), VoteCount: 200},
+		{Title: "The Godfather Part II", ReleaseDate: date("1974-12-20"), VoteCount: 100},
+		{Title: "Amélie", ReleaseDate: date("2001-04-25")},
+		{Title: "Forrest Gump"},
+	} {
+		mustCreate(t, r, m)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{"accents", "amelie", 10, []string{"Amélie 2001"}},
+		{"typo", "godfahter", 10, []string{"The Godfather 1972", "The Godfather Part II 1974"}},
+		{"extra word", "godfather 2", 10, []string{"The Godfather 1972", "The Godfather Part II 1974"}},
+		{"closest first", "godfather part", 10, []string{"The Godfather Part II 1974", "The Godfather 1972"}},
+		{"prefix", "forr", 10, []string{"Forrest Gump 0"}},
+		{"limit", "godfather", 1, []string{"The Godfather 1972"}},
+		{"no match", "xyzzy", 10, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := r.SuggestMovies(t.Context(), tt.query, tt.limit)
+			if err != nil {
+				t.Fatalf("SuggestMovies: %v", err)
+			}
+			got := make([]string, len(suggestions))
+			for i, s := range suggestions {
+				got[i] = fmt.Sprintf("%s %d", s.Title, s.Year)
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("SuggestMovies(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func testFilters(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, crime, comedy}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []models.Movie{
+		{Title: "The Godfather", Description: "A crime family", ReleaseDate: date("1972-03-14"), Rating: 8.7, Genres: []models.Genre{drama, crime}},
+		{Title: "Amélie", Description: "A shy waitress in Paris", ReleaseDate: date("2001-04-25"), Rating: 7.9, Genres: []models.Genre{comedy}},
+		{Title: "Forrest Gump", Description: "Life is like a box of chocolates", ReleaseDate: date("1994-06-23"), Rating: 8.5, Genres: []models.Genre{comedy, drama}},
+		{Title: "Pulp Fiction", Description: "Crime stories intertwine", ReleaseDate: date("1994-09-10"), Rating: 8.5, Genres: []models.Genre{crime}},
+	}
+	for i, m := range fixtures {
+		m.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		mustCreate(t, r, m)
+	}
+
+	rating := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		params models.MovieQueryParams
+		want   []string
+	}{
+		{"search title ignores case", models.MovieQueryParams{Search: "godFATHER"}, []string{"The Godfather"}},
+		{"search description", models.MovieQueryParams{Search: "crime"}, []string{"Pulp Fiction", "The Godfather"}},
+		{"min rating", models.MovieQueryParams{MinRating: rating(8.5)}, []string{"Pulp Fiction", "Forrest Gump", "The Godfather"}},
+		{"max rating", models.MovieQueryParams{MaxRating: rating(8.0)}, []string{"Amélie"}},
+		{"release range", models.MovieQueryParams{ReleaseFrom: "1994-01-01", ReleaseTo: "1994-09-10"}, []string{"Pulp Fiction", "Forrest Gump"}},
+		{"invalid date ignored", models.MovieQueryParams{ReleaseFrom: "not-a-date"}, []string{"Pulp Fiction", "Forrest Gump", "Amélie", "The Godfather"}},
+		{"all genres by id", models.MovieQueryParams{Genre: "18,35"}, []string{"Forrest Gump"}},
+		{"all genres by name", models.MovieQueryParams{Genre: "crime, Drama"}, []string{"The Godfather"}},
+		{"unknown genre name", models.MovieQueryParams{Genre: "drama,western"}, nil},
+		{"any genre", models.MovieQueryParams{GenreAny: "comedy,80"}, []string{"Pulp Fiction", "Forrest Gump", "Amélie", "The Godfather"}},
+		{"any unknown genre", models.MovieQueryParams{GenreAny: "western"}, nil},
+		{"combined", models.MovieQueryParams{GenreAny: "crime", MinRating: rating(8.6)}, []string{"The Godfather"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Page, tt.params.Limit = 1, 10
+			resp, err := r.GetMoviesWithPagination(t.Context(), tt.params)
+			if err != nil {
+				t.Fatalf("GetMoviesWithPagination: %v", err)
+			}
+			got := titles(t, resp)
+			if !equalStrings(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if resp.Total == nil || *resp.Total != int64(len(tt.want)) {
+				t.Errorf("total = %v, want %d", deref(resp.Total), len(tt.want))
+			}
+		})
+	}
+}
+
+func testGenres(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama, {ID: 35, Name: "Komedie"}}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	// Saving again renames by ID
+	if err := r.SaveGenres(t.Context(), []models.Genre{comedy, crime}); err != nil {
+		t.Fatalf("SaveGenres again: %v", err)
+	}
+
+	genres, err := r.GetAllGenres(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllGenres: %v", err)
+	}
+	var names []string
+	for _, g := range genres {
+		names = append(names, g.Name)
+	}
+	if !equalStrings(names, []string{"Comedy", "Crime", "Drama"}) {
+		t.Errorf("GetAllGenres = %v, want [Comedy Crime Drama]", names)
+	}
+}
+
+func testCredits(t *testing.T, r repository.Repository) {
+	older := mustCreate(t, r, models.Movie{ExternalID: "238", Title: "The Godfather", ReleaseDate: date("1972-03-14")})
+	newer := mustCreate(t, r, models.Movie{ExternalID: "240", Title: "The Godfather Part II", ReleaseDate: date("1974-12-20")})
+
+	pacino := &models.Person{ExternalID: "1158", Name: "Al Pacino", KnownForDepartment: "Acting"}
+	coppola := &models.Person{ExternalID: "1776", Name: "Francis Ford Coppola", KnownForDepartment: "Directing"}
+	credits := []models.Credit{
+		{CreditID: "c1", Person: coppola, Role: models.CreditRoleCrew, Department: "Directing", Job: "Director", Order: 0},
+		{CreditID: "c2", Person: pacino, Role: models.CreditRoleCast, Character: "Michael Corleone", Order: 1},
+		{CreditID: "c3", Person: coppola, Role: models.CreditRoleCast, Character: "Cameo", Order: 0},
+	}
+	if err := r.SaveMovieCredits(t.Context(), older.ID, credits); err != nil {
+		t.Fatalf("SaveMovieCredits: %v", err)
+	}
+
+	got, err := r.GetMovieCredits(t.Context(), older.ID)
+	if err != nil {
+		t.Fatalf("GetMovieCredits: %v", err)
+	}
+	if len(got.Cast) != 2 || len(got.Crew) != 1 {
+		t.Fatalf("got %d cast and %d crew, want 2 and 1", len(got.Cast), len(got.Crew))
+	}
+	if got.Cast[0].Character != "Cameo" || got.Cast[1].Character != "Michael Corleone" {
+		t.Errorf("cast order = %q, %q; want Cameo, Michael Corleone", got.Cast[0].Character, got.Cast[1].Character)
+	}
+	if got.Cast[0].Person == nil || got.Crew[0].Person == nil || got.Cast[0].PersonID != got.Crew[0].PersonID {
+		t.Errorf("a person in cast and crew must be stored once with the person attached")
+	}
+
+	// Saving again replaces the movie's credits and updates the people
+	pacino.Name = "Alfredo James Pacino"
+	if err := r.SaveMovieCredits(t.Context(), older.ID, credits[1:2]); err != nil {
+		t.Fatalf("SaveMovieCredits again: %v", err)
+	}
+	got, err = r.GetMovieCredits(t.Context(), older.ID)
+	if err != nil {
+		t.Fatalf("GetMovieCredits: %v", err)
+	}
+	if len(got.Cast) != 1 || len(got.Crew) != 0 {
+		t.Fatalf("after replace got %d cast and %d crew, want 1 and 0", len(got.Cast), len(got.Crew))
+	}
+	personID := got.Cast[0].PersonID
+	if err := r.SaveMovieCredits(t.Context(), newer.ID, []models.Credit{
+		{CreditID: "c4", Person: pacino, Role: models.CreditRoleCast, Character: "Michael Corleone"},
+	}); err != nil {
+		t.Fatalf("SaveMovieCredits(newer): %v", err)
+	}
+
+	person, err := r.GetPersonByID(t.Context(), personID)
+	if err != nil {
+		t.Fatalf("GetPersonByID: %v", err)
+	}
+	if person.Name != "Alfredo James Pacino" {
+		t.Errorf("person name = %q, want the updated name", person.Name)
+	}
+	if _, err := r.GetPersonByID(t.Context(), personID+100); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetPersonByID(missing) error = %v, want ErrNotFound", err)
+	}
+
+	filmography, err := r.GetPersonCredits(t.Context(), personID)
+	if err != nil {
+		t.Fatalf("GetPersonCredits: %v", err)
+	}
+	if len(filmography) != 2 {
+		t.Fatalf("GetPersonCredits returned %d credits, want 2", len(filmography))
+	}
+	if filmography[0].Movie == nil || filmography[0].Movie.ID != newer.ID || filmography[1].MovieID != older.ID {
+		t.Errorf("GetPersonCredits must attach the movie and list the newest release first")
+	}
+}
+
+func testCanceledContext(t *testing.T, r repository.Repository) {
+	m := mustCreate(t, r, models.Movie{Title: "Interrupted", ReleaseDate: date("2000-01-01")})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := r.GetMovieByID(ctx, m.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetMovieByID with canceled context error = %v, want context.Canceled", err)
+	}
+	if err := r.CreateMovie(ctx, &models.Movie{Title: "Never stored"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateMovie with canceled context error = %v, want context.Canceled", err)
+	}
+	all, err := r.GetAllMovies(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllMovies: %v", err)
+	}
+	if len(all) != 1 {
+		t.Errorf("GetAllMovies returned %d movies, want 1", len(all))
+	}
+}
+
+func testGetMoviesByExternalIDs(t *testing.T, r repository.Repository) {
+	if err := r.SaveGenres(t.Context(), []models.Genre{drama}); err != nil {
+		t.Fatalf("SaveGenres: %v", err)
+	}
+	mustCreate(t, r, models.Movie{ExternalID: "278", Title: "The Shawshank Redemption", ReleaseDate: date("1994-09-23"), Genres: []models.Genre{drama}})
+	mustCreate(t, r, models.Movie{ExternalID: "680", Title: "Pulp Fiction", ReleaseDate: date("1994-09-10")})
+	mustCreate(t, r, models.Movie{Title: "Home Movie", ReleaseDate: date("2020-01-01")})
+
+	movies, err := r.GetMoviesByExternalIDs(t.Context(), []string{"278", "999", ""})
+	if err != nil {
+		t.Fatalf("GetMoviesByExternalIDs: %v", err)
+	}
+	if len(movies) != 1 || movies[0].ExternalID != "278" {
+		t.Fatalf("GetMoviesByExternalIDs = %+v, want only 278", movies)
+	}
+	if ids := genreIDs(movies[0].Genres); !equalIDs(ids, []uint{18}) {
+		t.Errorf("genres = %v, want [18]", ids)
+	}
+
+	movies, err = r.GetMoviesByExternalIDs(t.Context(), nil)
+	if err != nil || len(movies) != 0 {
+		t.Errorf("GetMoviesByExternalIDs(nil) = %v, %v; want none", movies, err)
+	}
+}
+
+func testTransaction(t *testing.T, r repository.Repository) {
+	errRollback := errors.New("rollback")
+	err := r.Transaction(t.Context(), func(tx repository.Repository) error {
+		mustCreate(t, tx, models.Movie{Title: "Rolled back", ReleaseDate: date("2000-01-01")})
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Transaction error = %v, want the error returned by fn", err)
+	}
+
+	err = r.Transaction(t.Context(), func(tx repository.Repository) error {
+		mustCreate(t, tx, models.Movie{Title: "Committed", ReleaseDate: date("2000-01-01")})
+		// A failed nested transaction only undoes its own writes
+		nested := tx.Transaction(t.Context(), func(tx repository.Repository) error {
+			mustCreate(t, tx, models.Movie{Title: "Nested", ReleaseDate: date("2000-01-01")})
+			return errRollback
+		})
+		if !errors.Is(nested, errRollback) {
+			t.Errorf("nested Transaction error = %v, want the error returned by fn", nested)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction: %v", err)
+	}
+
+	all, err := r.GetAllMovies(t.Context())
+	if err != nil {
+		t.Fatalf("GetAllMovies: %v", err)
+	}
+	if len(all) != 1 || all[0].Title != "Committed" {
+		t.Errorf("after transactions got %d movies, want only the committed one", len(all))
+	}
+}
+
+func mustCreateJob(t *testing.T, r repository.Repository, source string) models.SyncJob {
+	t.Helper()
+	job := models.SyncJob{Source: source, State: models.SyncJobQueued}
+	if err := r.CreateSyncJob(t.Context(), &job); err != nil {
+		t.Fatalf("CreateSyncJob(%q): %v", source, err)
+	}
+	if job.ID == 0 {
+		t.Fatalf("CreateSyncJob(%q) left ID unset", source)
+	}
+	return job
+}
+
+func testSyncJobs(t *testing.T, r repository.Repository) {
+	job := mustCreateJob(t, r, "popular")
+
+	// Only one active job per source
+	dup := models.SyncJob{Source: "popular", State: models.SyncJobQueued}
+	if err := r.CreateSyncJob(t.Context(), &dup); !errors.Is(err, repository.ErrSyncJobActive) {
+		t.Fatalf("second CreateSyncJob error = %v, want ErrSyncJobActive", err)
+	}
+	other := mustCreateJob(t, r, "top_rated")
+
+	active, err := r.GetActiveSyncJob(t.Context(), "popular")
+	if err != nil || active == nil || active.ID != job.ID {
+		t.Fatalf("GetActiveSyncJob = %+v, %v; want job %d", active, err, job.ID)
+	}
+
+	started := time.Now()
+	job.State = models.SyncJobRunning
+	job.StartedAt = &started
+	job.Progress = models.SyncProgress{Stage: "movies", Done: 1, Total: 2}
+	if err := r.UpdateSyncJob(t.Context(), &job); err != nil {
+		t.Fatalf("UpdateSyncJob: %v", err)
+	}
+	finished := time.Now()
+	job.State = models.SyncJobSucceeded
+	job.FinishedAt = &finished
+	job.Report = &models.SyncReport{Source: "popular", Fetched: 2, Created: 1, Failed: 1,
+		Errors: []models.SyncError{{ExternalID: "1", Title: "Broken", Error: "boom"}}}
+	if err := r.UpdateSyncJob(t.Context(), &job); err != nil {
+		t.Fatalf("UpdateSyncJob: %v", err)
+	}
+
+	got, err := r.GetSyncJob(t.Context(), job.ID)
+	if err != nil {
+		t.Fatalf("GetSyncJob: %v", err)
+	}
+	if got.State != models.SyncJobSucceeded || got.Progress != job.Progress || got.StartedAt == nil || got.FinishedAt == nil {
+		t.Errorf("GetSyncJob = %+v, want the saved state", got)
+	}
+	if got.Report == nil || got.Report.Created != 1 || len(got.Report.Errors) != 1 || got.Report.Errors[0].Title != "Broken" {
+		t.Errorf("report = %+v, want the saved report", got.Report)
+	}
+
+	active, err = r.GetActiveSyncJob(t.Context(), "popular")
+	if err != nil || active != nil {
+		t.Errorf("GetActiveSyncJob after finishing = %+v, %v; want nil", active, err)
+	}
+	next := mustCreateJob(t, r, "popular")
+
+	jobs, err := r.ListSyncJobs(t.Context(), 2)
+	if err != nil {
+		t.Fatalf("ListSyncJobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != next.ID || jobs[1].ID != other.ID {
+		t.Errorf("ListSyncJobs(2) = %+v, want jobs %d and %d", jobs, next.ID, other.ID)
+	}
+
+	if _, err := r.GetSyncJob(t.Context(), 999); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetSyncJob(999) error = %v, want ErrNotFound", err)
+	}
+	missing := models.SyncJob{ID: 999, Source: "popular", State: models.SyncJobFailed}
+	if err := r.UpdateSyncJob(t.Context(), &missing); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateSyncJob(999) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testFailActiveSyncJobs(t *testing.T, r repository.Repository) {
+	running := mustCreateJob(t, r, "popular")
+	running.State = models.SyncJobRunning
+	if err := r.UpdateSyncJob(t.Context(), &running); err != nil {
+		t.Fatalf("UpdateSyncJob: %v", err)
+	}
+	done := mustCreateJob(t, r, "top_rated")
+	done.State = models.SyncJobSucceeded
+	if err := r.UpdateSyncJob(t.Context(), &done); err != nil {
+		t.Fatalf("UpdateSyncJob: %v", err)
+	}
+
+	n, err := r.FailActiveSyncJobs(t.Context(), time.Now().Add(-time.Hour), "stale")
+	if err != nil || n != 0 {
+		t.Fatalf("FailActiveSyncJobs(an hour ago) = %d, %v; want 0", n, err)
+	}
+
+	// A heartbeat keeps the running job out of a cutoff taken before it
+	cutoff := time.Now()
+	if err := r.TouchSyncJob(t.Context(), running.ID); err != nil {
+		t.Fatalf("TouchSyncJob: %v", err)
+	}
+	if err := r.TouchSyncJob(t.Context(), done.ID); err != nil {
+		t.Fatalf("TouchSyncJob(finished job): %v", err)
+	}
+	n, err = r.FailActiveSyncJobs(t.Context(), cutoff, "stale")
+	if err != nil || n != 0 {
+		t.Fatalf("FailActiveSyncJobs(before the heartbeat) = %d, %v; want 0", n, err)
+	}
+
+	n, err = r.FailActiveSyncJobs(t.Context(), time.Now().Add(time.Second), "interrupted")
+	if err != nil || n != 1 {
+		t.Fatalf("FailActiveSyncJobs = %d, %v; want 1", n, err)
+	}
+
+	got, err := r.GetSyncJob(t.Context(), running.ID)
+	if err != nil {
+		t.Fatalf("GetSyncJob: %v", err)
+	}
+	if got.State != models.SyncJobFailed || got.Error != "interrupted" || got.FinishedAt == nil {
+		t.Errorf("interrupted job = %+v, want failed with the message", got)
+	}
+	if got, _ := r.GetSyncJob(t.Context(), done.ID); got == nil || got.State != models.SyncJobSucceeded {
+		t.Errorf("finished job = %+v, want it left alone", got)
+	}
+	mustCreateJob(t, r, "popular")
+}
+
+func testSyncSchedules(t *testing.T, r repository.Repository) {
+	state, err := r.GetSyncScheduleState(t.Context(), "nightly")
+	if err != nil || state != nil {
+		t.Fatalf("GetSyncScheduleState before any write = %+v, %v; want nil", state, err)
+	}
+
+	if err := r.SetSyncSchedulePaused(t.Context(), "nightly", true); err != nil {
+		t.Fatalf("SetSyncSchedulePaused: %v", err)
+	}
+	ran := time.Now()
+	jobID := uint(7)
+	run := models.SyncScheduleState{Name: "nightly", LastRunAt: &ran, LastJobID: &jobID, LastError: "boom"}
+	if err := r.RecordSyncScheduleRun(t.Context(), &run); err != nil {
+		t.Fatalf("RecordSyncScheduleRun: %v", err)
+	}
+	// Recording a run leaves the pause alone
+	state, err = r.GetSyncScheduleState(t.Context(), "nightly")
+	if err != nil || state == nil {
+		t.Fatalf("GetSyncScheduleState = %+v, %v", state, err)
+	}
+	if !state.Paused || state.LastRunAt == nil || state.LastJobID == nil || *state.LastJobID != 7 || state.LastError != "boom" {
+		t.Errorf("state = %+v, want paused with the recorded run", state)
+	}
+
+	// Recording a run of a schedule without state creates it unpaused
+	if err := r.RecordSyncScheduleRun(t.Context(), &models.SyncScheduleState{Name: "hourly", LastRunAt: &ran}); err != nil {
+		t.Fatalf("RecordSyncScheduleRun: %v", err)
+	}
+	if err := r.SetSyncSchedulePaused(t.Context(), "nightly", false); err != nil {
+		t.Fatalf("SetSyncSchedulePaused: %v", err)
+	}
+
+	states, err := r.GetSyncScheduleStates(t.Context())
+	if err != nil {
+		t.Fatalf("GetSyncScheduleStates: %v", err)
+	}
+	if len(states) != 2 || states[0].Name != "hourly" || states[1].Name != "nightly" {
+		t.Fatalf("GetSyncScheduleStates = %+v, want hourly and nightly", states)
+	}
+	if states[0].Paused || states[1].Paused || states[1].LastError != "boom" {
+		t.Errorf("states = %+v, want both active and nightly's run kept", states)
+	}
+}
+
+func testSyncWatermarks(t *testing.T, r repository.Repository) {
+	mark, err := r.GetSyncWatermark(t.Context(), "changes")
+	if err != nil || mark != nil {
+		t.Fatalf("GetSyncWatermark before any write = %+v, %v; want nil", mark, err)
+	}
+
+	first := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	for _, until := range []time.Time{first, second} {
+		if err := r.SetSyncWatermark(t.Context(), "changes", until); err != nil {
+			t.Fatalf("SetSyncWatermark(%v): %v", until, err)
+		}
+	}
+
+	mark, err = r.GetSyncWatermark(t.Context(), "changes")
+	if err != nil || mark == nil {
+		t.Fatalf("GetSyncWatermark = %+v, %v", mark, err)
+	}
+	if !mark.SyncedUntil.Equal(second) {
+		t.Errorf("SyncedUntil = %v, want %v", mark.SyncedUntil, second)
+	}
+	if other, err := r.GetSyncWatermark(t.Context(), "popular"); err != nil || other != nil {
+		t.Errorf("GetSyncWatermark(popular) = %+v, %v; want nil", other, err)
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+)
+
+// activeStates are the job states that block a new job for the same source
+var activeStates = []models.SyncJobState{models.SyncJobQueued, models.SyncJobRunning}
+
+// CreateSyncJob stores a new sync job unless its source already has an active one
+func (r *GormRepository) CreateSyncJob(ctx context.Context, job *models.SyncJob) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&models.SyncJob{}).
+			Where("source = ? AND state IN ?", job.Source, activeStates).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrSyncJobActive
+		}
+		return tx.Create(job).Error
+	})
+	if err != nil && err != ErrSyncJobActive {
+		// Another instance may have won the race; the unique index on active
+		// jobs rejects the insert
+		if active, _ := r.GetActiveSyncJob(ctx, job.Source); active != nil {
+			return ErrSyncJobActive
+		}
+	}
+	return err
+}
+
+// UpdateSyncJob saves the state, progress and outcome of a sync job
+func (r *GormRepository) UpdateSyncJob(ctx context.Context, job *models.SyncJob) error {
+	// Save would insert a missing row, so update every column in place instead
+	result := r.db.WithContext(ctx).Model(job).Select("*").Omit("id", "created_at").Updates(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchSyncJob bumps the updated_at of an active sync job
+func (r *GormRepository) TouchSyncJob(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SyncJob{}).
+		Where("id = ? AND state IN ?", id, activeStates).
+		Update("updated_at", time.Now()).Error
+}
+
+// GetSyncJob gets a sync job by ID
+func (r *GormRepository) GetSyncJob(ctx context.Context, id uint) (*models.SyncJob, error) {
+	var job models.SyncJob
+	if err := r.db.WithContext(ctx).First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetActiveSyncJob gets the queued or running job of a source
+func (r *GormRepository) GetActiveSyncJob(ctx context.Context, source string) (*models.SyncJob, error) {
+	var job models.SyncJob
+	err := r.db.WithContext(ctx).
+		Where("source = ? AND state IN ?", source, activeStates).
+		First(&job).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListSyncJobs retrieves the most recent sync jobs
+func (r *GormRepository) ListSyncJobs(ctx context.Context, limit int) ([]models.SyncJob, error) {
+	jobs := []models.SyncJob{}
+	err := r.db.WithContext(ctx).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// FailActiveSyncJobs marks active jobs that stopped reporting progress as failed
+func (r *GormRepository) FailActiveSyncJobs(ctx context.Context, updatedBefore time.Time, message string) (int64, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&models.SyncJob{}).
+		Where("state IN ? AND updated_at < ?", activeStates, updatedBefore).
+		Updates(map[string]interface{}{
+			"state":       models.SyncJobFailed,
+			"error":       message,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	return result.RowsAffected, result.Error
+}
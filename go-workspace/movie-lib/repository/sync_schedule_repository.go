@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSyncScheduleStates retrieves the stored state of every schedule
+func (r *GormRepository) GetSyncScheduleStates(ctx context.Context) ([]models.SyncScheduleState, error) {
+	states := []models.SyncScheduleState{}
+	if err := r.db.WithContext(ctx).Order("name").Find(&states).Error; err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// GetSyncScheduleState gets the stored state of a schedule
+func (r *GormRepository) GetSyncScheduleState(ctx context.Context, name string) (*models.SyncScheduleState, error) {
+	var state models.SyncScheduleState
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&state).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SetSyncSchedulePaused pauses or resumes a schedule
+func (r *GormRepository) SetSyncSchedulePaused(ctx context.Context, name string, paused bool) error {
+	state := models.SyncScheduleState{Name: name, Paused: paused, UpdatedAt: time.Now()}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"paused", "updated_at"}),
+	}).Create(&state).Error
+}
+
+// RecordSyncScheduleRun stores the outcome of a schedule's last run
+func (r *GormRepository) RecordSyncScheduleRun(ctx context.Context, state *models.SyncScheduleState) error {
+	record := *state
+	record.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_run_at", "last_job_id", "last_error", "updated_at"}),
+	}).Create(&record).Error
+}
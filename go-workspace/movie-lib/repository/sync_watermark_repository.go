@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSyncWatermark gets the watermark of an incremental sync source
+func (r *GormRepository) GetSyncWatermark(ctx context.Context, source string) (*models.SyncWatermark, error) {
+	var mark models.SyncWatermark
+	if err := r.db.WithContext(ctx).Where("source = ?", source).First(&mark).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mark, nil
+}
+
+// SetSyncWatermark moves the watermark of an incremental sync source
+func (r *GormRepository) SetSyncWatermark(ctx context.Context, source string, syncedUntil time.Time) error {
+	mark := models.SyncWatermark{Source: source, SyncedUntil: syncedUntil, UpdatedAt: time.Now()}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}},
+		DoUpdates: clause.AssignmentColumns([]string{"synced_until", "updated_at"}),
+	}).Create(&mark).Error
+}
@@ -0,0 +1,25 @@
+package service
+
+import "context"
+
+// Elector decides which of the instances sharing a database runs a
+// schedule, so each scheduled sync runs once rather than once per instance
+type Elector interface {
+	// TryLead reports whether this instance leads key, taking the lead if
+	// no instance holds it. The lead is kept until Resign or the instance stops
+	TryLead(ctx context.Context, key string) (bool, error)
+	// Resign gives up the lead of key
+	Resign(key string)
+}
+
+// LocalElector makes this instance the leader of everything. Use it when a
+// single instance owns the database, as with SQLite
+type LocalElector struct{}
+
+// TryLead always leads
+func (LocalElector) TryLead(ctx context.Context, key string) (bool, error) {
+	return true, ctx.Err()
+}
+
+// Resign does nothing
+func (LocalElector) Resign(key string) {}
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/rohankarmacharya/movie-lib/client"
@@ -9,45 +14,378 @@ import (
 	"github.com/rohankarmacharya/movie-lib/repository"
 )
 
-func SyncWithAPI() error {
-	movies, err := client.FetchMovies()
+// TMDB is the part of the TMDB client the sync needs. *client.TMDBClient
+// implements it
+type TMDB interface {
+	FetchList(ctx context.Context, q client.ListQuery) ([]models.Movie, error)
+	FetchMovieDetails(ctx context.Context, movieID string) (*models.Movie, error)
+	FetchChangedMovieIDs(ctx context.Context, start, end time.Time) ([]string, error)
+	FetchMovieCredits(ctx context.Context, movieID string) (*client.TMDBCredits, error)
+	FetchGenres(ctx context.Context) ([]models.Genre, error)
+}
+
+// DefaultSyncBatchSize is the number of movies written per transaction
+const DefaultSyncBatchSize = 100
+
+// Sync sources. Every TMDB movie list is a source too, named by the list and
+// its parameters in query form, like "trending?window=week" or
+// "discover?genres=18&year=1994"; see client.ParseListQuery
+const (
+	// SourcePopular syncs TMDB's popular movies list
+	SourcePopular = string(client.ListPopular)
+	// SourceChanges refreshes the stored movies TMDB changed since the last
+	// successful changes sync
+	SourceChanges = "changes"
+)
+
+// DefaultChangesLookback is how far back the first changes sync looks, as
+// there is no watermark yet
+const DefaultChangesLookback = 24 * time.Hour
+
+// ErrUnknownSource is returned when syncing a source the service can't fetch
+var ErrUnknownSource = errors.New("unknown sync source")
+
+// ProgressFunc receives progress updates while a sync runs. It is called
+// from the syncing goroutine and should return quickly
+type ProgressFunc func(models.SyncProgress)
+
+// SyncService copies TMDB data into a repository
+type SyncService struct {
+	repo      repository.Repository
+	tmdb      TMDB
+	batchSize int
+	policy    models.MergePolicy
+}
+
+// Option configures a SyncService
+type Option func(*SyncService)
+
+// WithBatchSize sets the number of movies written per transaction
+func WithBatchSize(size int) Option {
+	return func(s *SyncService) {
+		if size > 0 {
+			s.batchSize = size
+		}
+	}
+}
+
+// WithMergePolicy sets which fields of stored movies a sync may overwrite
+func WithMergePolicy(policy models.MergePolicy) Option {
+	return func(s *SyncService) {
+		if policy.Valid() {
+			s.policy = policy
+		}
+	}
+}
+
+// NewSyncService creates a sync service writing TMDB data to repo
+func NewSyncService(repo repository.Repository, tmdb TMDB, opts ...Option) *SyncService {
+	s := &SyncService{repo: repo, tmdb: tmdb, batchSize: DefaultSyncBatchSize, policy: models.DefaultMergePolicy}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ValidSource reports whether source can be synced
+func ValidSource(source string) bool {
+	_, err := CanonicalSource(source)
+	return err == nil
+}
+
+// CanonicalSource checks a source and returns it with its list parameters
+// in a fixed order, so each source has a single name
+func CanonicalSource(source string) (string, error) {
+	if source == SourceChanges {
+		return source, nil
+	}
+	q, err := parseListSource(source)
 	if err != nil {
-		return fmt.Errorf("failed to fetch movies: %w", err)
+		return "", err
+	}
+	return q.String(), nil
+}
+
+// parseListSource parses a source naming a TMDB movie list
+func parseListSource(source string) (client.ListQuery, error) {
+	list, rawQuery, _ := strings.Cut(source, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return client.ListQuery{}, fmt.Errorf("%w: %q: %v", ErrUnknownSource, source, err)
+	}
+	q, err := client.ParseListQuery(list, params)
+	if err != nil {
+		return q, fmt.Errorf("%w: %q: %w", ErrUnknownSource, source, err)
+	}
+	return q, nil
+}
+
+// fetch gets the movies of a list source from TMDB
+func (s *SyncService) fetch(ctx context.Context, source string) ([]models.Movie, error) {
+	q, err := parseListSource(source)
+	if err != nil {
+		return nil, err
+	}
+	return s.tmdb.FetchList(ctx, q)
+}
+
+// Sync fetches the movies of a source from TMDB and stores them in the DB.
+// Movies are matched by external_id, falling back to title and release date
+// for legacy rows stored without one, and merged with the stored movies as
+// the merge policy says. progress, if not nil, is told about
+// every stage, batch and movie. The report is returned even when the sync
+// stops part way
+func (s *SyncService) Sync(ctx context.Context, source string, progress ProgressFunc) (*models.SyncReport, error) {
+	source, err := CanonicalSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(models.SyncProgress) {}
+	}
+
+	report := &models.SyncReport{Source: source, StartedAt: time.Now()}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	progress(models.SyncProgress{Stage: "genres"})
+	if err := s.SyncGenres(ctx); err != nil {
+		return report, err
+	}
+
+	var movies []models.Movie
+	var until time.Time
+	if source == SourceChanges {
+		// Cached details may predate the change we are syncing
+		ctx = client.Revalidate(ctx)
+		until = time.Now()
+		movies, err = s.fetchChanged(ctx, until, report, progress)
+	} else {
+		movies, err = s.fetch(ctx, source)
+		report.Fetched = len(movies)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch movies: %w", err)
+	}
+
+	var written, unchanged []models.Movie
+	progress(models.SyncProgress{Stage: "movies", Total: len(movies)})
+	for start := 0; start < len(movies); start += s.batchSize {
+		end := min(start+s.batchSize, len(movies))
+		w, u, err := s.syncBatch(ctx, movies[start:end], report)
+		if err != nil {
+			return report, fmt.Errorf("failed to save movies: %w", err)
+		}
+		written = append(written, w...)
+		unchanged = append(unchanged, u...)
+		progress(models.SyncProgress{Stage: "movies", Done: end, Total: len(movies)})
 	}
 
-	for _, m := range movies {
-		releaseDate := m.ReleaseDate
+	// Credits take one TMDB call per movie, so fetch them outside the
+	// write transactions and only for movies that changed. A reported
+	// change may be to the credits alone, so changed movies all get theirs
+	refresh := written
+	if source == SourceChanges {
+		refresh = append(refresh, unchanged...)
+	}
+	progress(models.SyncProgress{Stage: "credits", Total: len(refresh)})
+	for i := range refresh {
+		if err := s.SyncCredits(ctx, &refresh[i]); err != nil {
+			if ctx.Err() != nil {
+				return report, err
+			}
+			report.Errors = append(report.Errors, syncError(refresh[i], fmt.Errorf("failed to sync credits: %w", err)))
+		}
+		progress(models.SyncProgress{Stage: "credits", Done: i + 1, Total: len(refresh)})
+	}
 
-		movie := models.Movie{
-			ExternalID:  m.ExternalID,
-			Title:       m.Title,
-			Description: m.Description,
-			ReleaseDate: releaseDate,
-			Rating:      m.Rating,
-			CreatedAt:   m.CreatedAt,
-			UpdatedAt:   time.Now(),
+	// Keep the watermark while some movies failed, so the next run
+	// retries their changes
+	if source == SourceChanges && report.Failed == 0 {
+		if err := s.repo.SetSyncWatermark(ctx, SourceChanges, until); err != nil {
+			return report, fmt.Errorf("failed to save the sync watermark: %w", err)
 		}
+	}
 
-		existingMovie, err := repository.GetMovieByTitleAndDate(m.Title, releaseDate)
+	return report, nil
+}
 
-		if err == nil && existingMovie != nil {
-			// Movie exists, update it
-			movie.ID = existingMovie.ID
-			err = repository.UpdateMovie(&movie)
+// fetchChanged gets fresh details of the stored movies TMDB changed between
+// the watermark and until. A movie TMDB no longer has is skipped; one whose
+// details fail to load is counted as failed
+func (s *SyncService) fetchChanged(ctx context.Context, until time.Time, report *models.SyncReport, progress ProgressFunc) ([]models.Movie, error) {
+	since := until.Add(-DefaultChangesLookback)
+	mark, err := s.repo.GetSyncWatermark(ctx, SourceChanges)
+	if err != nil {
+		return nil, err
+	}
+	if mark != nil {
+		since = mark.SyncedUntil
+	}
+
+	progress(models.SyncProgress{Stage: "changes"})
+	changedIDs, err := s.tmdb.FetchChangedMovieIDs(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only movies we hold are refreshed; the rest of TMDB's changes are
+	// no concern of ours
+	var stored []models.Movie
+	for start := 0; start < len(changedIDs); start += s.batchSize {
+		batch, err := s.repo.GetMoviesByExternalIDs(ctx, changedIDs[start:min(start+s.batchSize, len(changedIDs))])
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, batch...)
+	}
+	report.Fetched = len(stored)
+
+	movies := make([]models.Movie, 0, len(stored))
+	progress(models.SyncProgress{Stage: "details", Total: len(stored)})
+	for i, m := range stored {
+		movie, err := s.tmdb.FetchMovieDetails(ctx, m.ExternalID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if errors.Is(err, client.ErrNotFound) {
+				report.Skipped++
+			} else {
+				report.Failed++
+			}
+			report.Errors = append(report.Errors, syncError(m, fmt.Errorf("failed to fetch details: %w", err)))
 		} else {
-			// Movie doesn't exist, create it
-			err = repository.CreateMovie(&movie)
+			movies = append(movies, *movie)
 		}
+		progress(models.SyncProgress{Stage: "details", Done: i + 1, Total: len(stored)})
+	}
+	return movies, nil
+}
+
+// syncBatch writes one batch of movies in a transaction, adds the outcome to
+// report and returns the movies it created or updated and the stored movies
+// that were already up to date. A movie that fails to save is counted as
+// failed without aborting the rest of the batch
+func (s *SyncService) syncBatch(ctx context.Context, movies []models.Movie, report *models.SyncReport) (written, unchanged []models.Movie, err error) {
+	var outcome models.SyncReport
 
+	err = s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		externalIDs := make([]string, 0, len(movies))
+		for _, m := range movies {
+			externalIDs = append(externalIDs, m.ExternalID)
+		}
+		stored, err := tx.GetMoviesByExternalIDs(ctx, externalIDs)
 		if err != nil {
-			return fmt.Errorf("failed to sync movie %s: %v", m.Title, err)
+			return err
+		}
+		byExternalID := make(map[string]models.Movie, len(stored))
+		for _, m := range stored {
+			byExternalID[m.ExternalID] = m
 		}
+
+		seen := make(map[string]bool, len(movies))
+		for _, m := range movies {
+			movie := m
+			if movie.ExternalID == "" || movie.Title == "" || seen[movie.ExternalID] {
+				outcome.Skipped++
+				continue
+			}
+			seen[movie.ExternalID] = true
+
+			existing, found := byExternalID[movie.ExternalID]
+			if !found {
+				legacy, err := tx.GetMovieByTitleAndDate(ctx, movie.Title, movie.ReleaseDate)
+				if err != nil {
+					return err
+				}
+				if legacy != nil && legacy.ExternalID == "" {
+					existing, found = *legacy, true
+				}
+			}
+
+			if found {
+				s.policy.Merge(&existing, &movie)
+			}
+			if found && sameMovie(existing, movie) {
+				outcome.Unchanged++
+				unchanged = append(unchanged, existing)
+				continue
+			}
+
+			movie.UpdatedAt = time.Now()
+			if found {
+				movie.ID = existing.ID
+				err = tx.UpdateMovie(ctx, &movie)
+			} else {
+				err = tx.CreateMovie(ctx, &movie)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				outcome.Failed++
+				outcome.Errors = append(outcome.Errors, syncError(movie, err))
+				continue
+			}
+
+			if found {
+				outcome.Updated++
+			} else {
+				outcome.Created++
+			}
+			written = append(written, movie)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	report.Created += outcome.Created
+	report.Updated += outcome.Updated
+	report.Unchanged += outcome.Unchanged
+	report.Skipped += outcome.Skipped
+	report.Failed += outcome.Failed
+	report.Errors = append(report.Errors, outcome.Errors...)
+	return written, unchanged, nil
+}
+
+// sameMovie reports whether saving incoming over stored would change nothing
+func sameMovie(stored, incoming models.Movie) bool {
+	return stored.ExternalID == incoming.ExternalID &&
+		stored.Popularity == incoming.Popularity &&
+		len(models.ChangedFields(&stored, &incoming)) == 0 &&
+		slices.Equal(stored.ManualFields, incoming.ManualFields)
+}
+
+func syncError(movie models.Movie, err error) models.SyncError {
+	return models.SyncError{ExternalID: movie.ExternalID, Title: movie.Title, Error: err.Error()}
+}
+
+// SyncCredits fetches the cast and crew of a stored movie from TMDB and saves them
+func (s *SyncService) SyncCredits(ctx context.Context, movie *models.Movie) error {
+	if movie.ExternalID == "" || movie.ID == 0 {
+		return nil
+	}
+
+	credits, err := s.tmdb.FetchMovieCredits(ctx, movie.ExternalID)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.SaveMovieCredits(ctx, movie.ID, credits.ToCredits())
 }
 
-// GetAllMovies fetches all movies from the repository
-func GetAllMovies() ([]models.Movie, error) {
-	return repository.GetAllMovies()
+// SyncGenres refreshes the genre catalogue from TMDB
+func (s *SyncService) SyncGenres(ctx context.Context) error {
+	genres, err := s.tmdb.FetchGenres(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch genres: %w", err)
+	}
+
+	if err := s.repo.SaveGenres(ctx, genres); err != nil {
+		return fmt.Errorf("failed to save genres: %w", err)
+	}
+
+	return nil
 }
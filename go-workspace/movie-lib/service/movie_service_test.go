@@ -0,0 +1,191 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/client"
+	"github.com/rohankarmacharya/movie-lib/client/tmdbfake"
+	"github.com/rohankarmacharya/movie-lib/config"
+	"github.com/rohankarmacharya/movie-lib/migrate"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/service"
+	"gorm.io/gorm/logger"
+)
+
+// repositories returns a fresh repository of each kind a sync can run against
+func repositories(t *testing.T) map[string]repository.Repository {
+	t.Helper()
+	cfg := config.Default().DB
+	cfg.Driver = "sqlite"
+	cfg.Path = filepath.Join(t.TempDir(), "movies.db")
+	db, err := config.ConnectDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	if err := migrate.New(db, migrate.All).Up(); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]repository.Repository{
+		"memory": repository.NewMemoryRepository(),
+		"sqlite": repository.NewSQLiteRepository(db),
+	}
+}
+
+// failingDetails is a TMDB whose details of one movie fail to load
+type failingDetails struct {
+	*client.TMDBClient
+	externalID string
+}
+
+func (f *failingDetails) FetchMovieDetails(ctx context.Context, movieID string) (*models.Movie, error) {
+	if movieID == f.externalID {
+		return nil, errors.New("connection reset")
+	}
+	return f.TMDBClient.FetchMovieDetails(ctx, movieID)
+}
+
+func TestListSyncOutcomes(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			fake := tmdbfake.NewServer()
+			defer fake.Close()
+			ctx := t.Context()
+			s := service.NewSyncService(repo, fake.Client(client.WithCache(nil)), service.WithBatchSize(3))
+
+			report, err := s.Sync(ctx, service.SourcePopular, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Fetched == 0 || report.Created != report.Fetched || report.Updated+report.Unchanged+report.Skipped+report.Failed != 0 {
+				t.Errorf("first sync = %+v, want every fetched movie created", *report)
+			}
+
+			report, err = s.Sync(ctx, service.SourcePopular, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Unchanged != report.Fetched || report.Created+report.Updated != 0 {
+				t.Errorf("second sync = %+v, want every movie unchanged", *report)
+			}
+
+			m := tmdbfake.DefaultMovies[0]
+			m.Title += " (Restored)"
+			fake.AddMovie(m)
+			report, err = s.Sync(ctx, service.SourcePopular, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Updated != 1 || report.Unchanged != report.Fetched-1 {
+				t.Errorf("sync after a change = %+v, want 1 updated", *report)
+			}
+		})
+	}
+}
+
+func TestChangesSyncOutcomes(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			fake := tmdbfake.NewServer()
+			defer fake.Close()
+			ctx := t.Context()
+			tmdb := &failingDetails{TMDBClient: fake.Client(client.WithCache(nil))}
+			s := service.NewSyncService(repo, tmdb)
+			if _, err := s.Sync(ctx, service.SourcePopular, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			// A clean run sets the watermark
+			report, err := s.Sync(ctx, service.SourceChanges, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Fetched != 0 {
+				t.Errorf("first changes sync fetched %d movies, want 0", report.Fetched)
+			}
+			first, err := repo.GetSyncWatermark(ctx, service.SourceChanges)
+			if err != nil || first == nil {
+				t.Fatalf("watermark = %v, %v; want one set", first, err)
+			}
+
+			updated, removed, broken := tmdbfake.DefaultMovies[0], tmdbfake.DefaultMovies[1], tmdbfake.DefaultMovies[2]
+			updated.Title += " (Restored)"
+			fake.AddMovie(updated)
+			fake.RemoveMovie(removed.ID)
+			fake.MarkChanged(broken.ID, time.Now())
+			tmdb.externalID = strconv.Itoa(broken.ID)
+
+			// A failed movie holds the watermark
+			report, err = s.Sync(ctx, service.SourceChanges, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Fetched != 3 || report.Updated != 1 || report.Skipped != 1 || report.Failed != 1 || len(report.Errors) != 2 {
+				t.Errorf("changes sync = %+v, want 1 updated, 1 skipped and 1 failed", *report)
+			}
+			held, err := repo.GetSyncWatermark(ctx, service.SourceChanges)
+			if err != nil || !held.SyncedUntil.Equal(first.SyncedUntil) {
+				t.Errorf("watermark after a failure = %v, %v; want it held at %v", held, err, first.SyncedUntil)
+			}
+
+			// Once the movie loads again, the retry catches it up and advances the watermark
+			tmdb.externalID = ""
+			report, err = s.Sync(ctx, service.SourceChanges, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Failed != 0 || report.Updated != 1 || report.Unchanged != 1 {
+				t.Errorf("retried changes sync = %+v, want the failed movie updated", *report)
+			}
+			advanced, err := repo.GetSyncWatermark(ctx, service.SourceChanges)
+			if err != nil || !advanced.SyncedUntil.After(first.SyncedUntil) {
+				t.Errorf("watermark after the retry = %v, %v; want it past %v", advanced, err, first.SyncedUntil)
+			}
+		})
+	}
+}
+
+func TestListSyncKeepsDetails(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			fake := tmdbfake.NewServer()
+			defer fake.Close()
+			ctx := t.Context()
+			s := service.NewSyncService(repo, fake.Client(client.WithCache(nil)))
+
+			if _, err := s.Sync(ctx, service.SourcePopular, nil); err != nil {
+				t.Fatal(err)
+			}
+			fake.MarkChanged(278, time.Now())
+			report, err := s.Sync(ctx, service.SourceChanges, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Updated != 1 {
+				t.Fatalf("changes sync updated %d movies, want 1", report.Updated)
+			}
+
+			report, err = s.Sync(ctx, service.SourcePopular, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Updated != 0 {
+				t.Errorf("popular sync updated %d movies, want 0", report.Updated)
+			}
+			movies, err := repo.GetMoviesByExternalIDs(ctx, []string{"278"})
+			if err != nil || len(movies) != 1 {
+				t.Fatalf("got %d movies, err %v", len(movies), err)
+			}
+			got := movies[0]
+			if got.Runtime != 142 || got.Budget != 25000000 || got.IMDbID != "tt0111161" || got.Tagline == "" || got.Status != "Released" {
+				t.Errorf("details lost: runtime=%d budget=%d imdb_id=%q tagline=%q status=%q", got.Runtime, got.Budget, got.IMDbID, got.Tagline, got.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/cron"
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+)
+
+// ErrUnknownSchedule is returned for a schedule name that isn't configured
+var ErrUnknownSchedule = errors.New("unknown sync schedule")
+
+// fireTimeout bounds the bookkeeping of one scheduled run: checking the
+// pause, taking the lead and queueing the job
+const fireTimeout = 30 * time.Second
+
+// Schedule runs syncs of Source whenever the cron expression Cron fires.
+// Each run is delayed by a random duration up to Jitter, so instances and
+// schedules firing at the same minute don't all hit TMDB at once
+type Schedule struct {
+	Name   string
+	Source string
+	Cron   string
+	Jitter time.Duration
+}
+
+// JobStarter queues background syncs. *SyncJobs implements it
+type JobStarter interface {
+	Start(ctx context.Context, source string) (*models.SyncJob, error)
+}
+
+// scheduled is a Schedule with its parsed expression and next run
+type scheduled struct {
+	Schedule
+	cron *cron.Schedule
+	next time.Time
+}
+
+// Scheduler queues sync jobs on cron schedules. Every instance runs a
+// Scheduler with the same schedules; at each run the elector lets only one
+// of them queue the job. Pausing is stored in the repository, so it applies
+// to all instances
+type Scheduler struct {
+	repo      repository.SyncScheduleRepository
+	jobs      JobStarter
+	elector   Elector
+	schedules []*scheduled
+	byName    map[string]*scheduled
+
+	mu     sync.Mutex // guards the next run of the schedules
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler for schedules. It reports every invalid
+// schedule at once
+func NewScheduler(repo repository.SyncScheduleRepository, jobs JobStarter, elector Elector, schedules []Schedule) (*Scheduler, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		repo:    repo,
+		jobs:    jobs,
+		elector: elector,
+		byName:  make(map[string]*scheduled, len(schedules)),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	var errs []error
+	for _, sched := range schedules {
+		if sched.Name == "" {
+			errs = append(errs, errors.New("sync schedule without a name"))
+			continue
+		}
+		if _, ok := s.byName[sched.Name]; ok {
+			errs = append(errs, fmt.Errorf("sync schedule %q is defined twice", sched.Name))
+			continue
+		}
+		if source, err := CanonicalSource(sched.Source); err != nil {
+			errs = append(errs, fmt.Errorf("sync schedule %q: %w", sched.Name, err))
+		} else {
+			sched.Source = source
+		}
+		if sched.Jitter < 0 {
+			errs = append(errs, fmt.Errorf("sync schedule %q: jitter must not be negative", sched.Name))
+		}
+		expr, err := cron.Parse(sched.Cron)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sync schedule %q: %w", sched.Name, err))
+			continue
+		}
+		sc := &scheduled{Schedule: sched, cron: expr}
+		s.schedules = append(s.schedules, sc)
+		s.byName[sched.Name] = sc
+	}
+	if err := errors.Join(errs...); err != nil {
+		cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start runs the schedules in the background until Stop
+func (s *Scheduler) Start() {
+	for _, sc := range s.schedules {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(sc)
+		}()
+	}
+}
+
+// Stop stops the schedules, waits for runs being queued and gives up the
+// lead of every schedule. Jobs already queued keep running
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	for _, sc := range s.schedules {
+		s.elector.Resign(sc.Name)
+	}
+}
+
+// loop waits for each run of a schedule and fires it
+func (s *Scheduler) loop(sc *scheduled) {
+	for {
+		next := sc.cron.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Sync schedule %q never fires", sc.Name)
+			return
+		}
+		if sc.Jitter > 0 {
+			next = next.Add(rand.N(sc.Jitter))
+		}
+		s.mu.Lock()
+		sc.next = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.fire(sc)
+	}
+}
+
+// fire queues a scheduled run unless the schedule is paused or another
+// instance leads it
+func (s *Scheduler) fire(sc *scheduled) {
+	ctx, cancel := context.WithTimeout(s.ctx, fireTimeout)
+	defer cancel()
+
+	state, err := s.repo.GetSyncScheduleState(ctx, sc.Name)
+	if err != nil {
+		log.Printf("Failed to read sync schedule %q: %v", sc.Name, err)
+		return
+	}
+	if state != nil && state.Paused {
+		return
+	}
+
+	lead, err := s.elector.TryLead(ctx, sc.Name)
+	if err != nil {
+		log.Printf("Failed to elect a leader for sync schedule %q: %v", sc.Name, err)
+		return
+	}
+	if !lead {
+		return
+	}
+
+	if _, err := s.run(ctx, sc); err != nil && !errors.Is(err, repository.ErrSyncJobActive) {
+		log.Printf("Failed to run sync schedule %q: %v", sc.Name, err)
+	}
+}
+
+// run queues a sync of the schedule's source and records the run. If the
+// source is already syncing, the active job is recorded and returned along
+// with repository.ErrSyncJobActive
+func (s *Scheduler) run(ctx context.Context, sc *scheduled) (*models.SyncJob, error) {
+	job, err := s.jobs.Start(ctx, sc.Source)
+
+	ran := time.Now()
+	record := models.SyncScheduleState{Name: sc.Name, LastRunAt: &ran}
+	if job != nil {
+		id := job.ID
+		record.LastJobID = &id
+	}
+	if err != nil {
+		record.LastError = err.Error()
+	}
+	if recordErr := s.repo.RecordSyncScheduleRun(ctx, &record); recordErr != nil {
+		log.Printf("Failed to record run of sync schedule %q: %v", sc.Name, recordErr)
+	}
+	return job, err
+}
+
+// List returns the schedules in configuration order
+func (s *Scheduler) List(ctx context.Context) ([]models.SyncSchedule, error) {
+	states, err := s.repo.GetSyncScheduleStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.SyncScheduleState, len(states))
+	for _, state := range states {
+		byName[state.Name] = state
+	}
+
+	schedules := make([]models.SyncSchedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		state, ok := byName[sc.Name]
+		if !ok {
+			state = models.SyncScheduleState{Name: sc.Name}
+		}
+		schedules = append(schedules, s.describe(sc, state))
+	}
+	return schedules, nil
+}
+
+// Get returns a schedule by name
+func (s *Scheduler) Get(ctx context.Context, name string) (*models.SyncSchedule, error) {
+	sc, ok := s.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchedule, name)
+	}
+	state, err := s.repo.GetSyncScheduleState(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &models.SyncScheduleState{Name: name}
+	}
+	schedule := s.describe(sc, *state)
+	return &schedule, nil
+}
+
+// describe combines a schedule with its stored state
+func (s *Scheduler) describe(sc *scheduled, state models.SyncScheduleState) models.SyncSchedule {
+	schedule := models.SyncSchedule{
+		SyncScheduleState: state,
+		Source:            sc.Source,
+		Cron:              sc.Cron,
+		Jitter:            sc.Jitter.String(),
+	}
+	s.mu.Lock()
+	if !sc.next.IsZero() && !state.Paused {
+		next := sc.next
+		schedule.NextRunAt = &next
+	}
+	s.mu.Unlock()
+	return schedule
+}
+
+// Pause stops the scheduled runs of a schedule on every instance
+func (s *Scheduler) Pause(ctx context.Context, name string) (*models.SyncSchedule, error) {
+	return s.setPaused(ctx, name, true)
+}
+
+// Resume restarts the scheduled runs of a paused schedule
+func (s *Scheduler) Resume(ctx context.Context, name string) (*models.SyncSchedule, error) {
+	return s.setPaused(ctx, name, false)
+}
+
+func (s *Scheduler) setPaused(ctx context.Context, name string, paused bool) (*models.SyncSchedule, error) {
+	if _, ok := s.byName[name]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchedule, name)
+	}
+	if err := s.repo.SetSyncSchedulePaused(ctx, name, paused); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+// Trigger runs a schedule now on this instance, even if it is paused. If
+// the source is already syncing, the active job is returned along with
+// repository.ErrSyncJobActive
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*models.SyncJob, error) {
+	sc, ok := s.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSchedule, name)
+	}
+	return s.run(ctx, sc)
+}
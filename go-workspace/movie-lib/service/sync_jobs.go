@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+)
+
+// Syncer runs one sync of a source. *SyncService implements it
+type Syncer interface {
+	Sync(ctx context.Context, source string, progress ProgressFunc) (*models.SyncReport, error)
+}
+
+const (
+	// DefaultJobTimeout bounds a single background sync
+	DefaultJobTimeout = 30 * time.Minute
+
+	// DefaultStaleAfter is how long an active job may go without a
+	// heartbeat before RecoverInterrupted takes its runner for dead
+	DefaultStaleAfter = 2 * time.Minute
+
+	// progressInterval is the least time between two progress saves
+	// within a stage, so a sync of many movies doesn't write a row per movie
+	progressInterval = time.Second
+
+	// saveTimeout bounds each write of a job's state
+	saveTimeout = 10 * time.Second
+)
+
+// interruptedMessage is the error of jobs left active by a stopped process
+const interruptedMessage = "interrupted by restart"
+
+// SyncJobs runs syncs in the background and records them as jobs, so their
+// state and report can be polled and outlive the process
+type SyncJobs struct {
+	repo       repository.SyncJobRepository
+	syncer     Syncer
+	timeout    time.Duration
+	staleAfter time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// JobsOption configures SyncJobs
+type JobsOption func(*SyncJobs)
+
+// WithJobTimeout bounds each sync job; 0 means no deadline
+func WithJobTimeout(timeout time.Duration) JobsOption {
+	return func(j *SyncJobs) {
+		if timeout >= 0 {
+			j.timeout = timeout
+		}
+	}
+}
+
+// WithStaleAfter sets how long an active job may go without a heartbeat
+// before it counts as interrupted. Running jobs beat four times per window
+func WithStaleAfter(d time.Duration) JobsOption {
+	return func(j *SyncJobs) {
+		if d > 0 {
+			j.staleAfter = d
+		}
+	}
+}
+
+// NewSyncJobs creates a job runner storing its jobs in repo
+func NewSyncJobs(repo repository.SyncJobRepository, syncer Syncer, opts ...JobsOption) *SyncJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &SyncJobs{repo: repo, syncer: syncer, timeout: DefaultJobTimeout, staleAfter: DefaultStaleAfter, ctx: ctx, cancel: cancel}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Start queues a sync of source and runs it in the background. The job
+// records the canonical name of the source. If the source already has an
+// active job, that job is returned along with repository.ErrSyncJobActive
+func (j *SyncJobs) Start(ctx context.Context, source string) (*models.SyncJob, error) {
+	source, err := CanonicalSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.ctx.Err(); err != nil {
+		return nil, errors.New("sync jobs are shutting down")
+	}
+
+	job := &models.SyncJob{Source: source, State: models.SyncJobQueued}
+	if err := j.repo.CreateSyncJob(ctx, job); err != nil {
+		if errors.Is(err, repository.ErrSyncJobActive) {
+			active, getErr := j.repo.GetActiveSyncJob(ctx, source)
+			if getErr != nil {
+				return nil, getErr
+			}
+			return active, err
+		}
+		return nil, err
+	}
+
+	queued := *job
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.run(job)
+	}()
+	return &queued, nil
+}
+
+// run executes a queued job and records its progress and outcome
+func (j *SyncJobs) run(job *models.SyncJob) {
+	ctx := j.ctx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	job.State = models.SyncJobRunning
+	job.StartedAt = &started
+	j.save(job)
+
+	var lastSave time.Time
+	progress := func(p models.SyncProgress) {
+		stageChanged := p.Stage != job.Progress.Stage
+		job.Progress = p
+		if stageChanged || p.Done == p.Total || time.Since(lastSave) >= progressInterval {
+			lastSave = time.Now()
+			j.save(job)
+		}
+	}
+
+	stop := j.heartbeat(job.ID)
+	report, err := j.syncer.Sync(ctx, job.Source, progress)
+	stop()
+
+	finished := time.Now()
+	job.Report = report
+	job.FinishedAt = &finished
+	switch {
+	case err == nil:
+		job.State = models.SyncJobSucceeded
+	case j.ctx.Err() != nil:
+		job.State = models.SyncJobFailed
+		job.Error = "interrupted by shutdown"
+	default:
+		job.State = models.SyncJobFailed
+		job.Error = err.Error()
+	}
+	j.save(job)
+}
+
+// save writes the job's current state. It doesn't use the job's context, so
+// the outcome of a canceled job is still recorded
+func (j *SyncJobs) save(job *models.SyncJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := j.repo.UpdateSyncJob(ctx, job); err != nil {
+		log.Printf("Failed to save sync job %d: %v", job.ID, err)
+	}
+}
+
+// heartbeat touches the job until the returned stop is called, so that
+// RecoverInterrupted on another instance sees it is still running
+func (j *SyncJobs) heartbeat(id uint) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(j.staleAfter / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+				if err := j.repo.TouchSyncJob(ctx, id); err != nil {
+					log.Printf("Failed to touch sync job %d: %v", id, err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Get returns a job by ID
+func (j *SyncJobs) Get(ctx context.Context, id uint) (*models.SyncJob, error) {
+	return j.repo.GetSyncJob(ctx, id)
+}
+
+// List returns the most recent jobs first
+func (j *SyncJobs) List(ctx context.Context, limit int) ([]models.SyncJob, error) {
+	return j.repo.ListSyncJobs(ctx, limit)
+}
+
+// RecoverInterrupted marks queued or running jobs whose heartbeat is older
+// than the stale window as failed, so their sources can be synced again.
+// Jobs of live instances sharing the database keep beating and are left alone
+func (j *SyncJobs) RecoverInterrupted(ctx context.Context) (int64, error) {
+	return j.repo.FailActiveSyncJobs(ctx, time.Now().Add(-j.staleAfter), interruptedMessage)
+}
+
+// WatchInterrupted runs RecoverInterrupted once per stale window until
+// Shutdown, catching the jobs of instances that stop while this one runs
+func (j *SyncJobs) WatchInterrupted() {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(j.staleAfter)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := j.RecoverInterrupted(j.ctx); err != nil && j.ctx.Err() == nil {
+					log.Printf("Failed to recover interrupted sync jobs: %v", err)
+				} else if n > 0 {
+					log.Printf("Marked %d interrupted sync jobs as failed", n)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown cancels the running jobs and waits until their outcome is saved
+// or ctx is done
+func (j *SyncJobs) Shutdown(ctx context.Context) error {
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
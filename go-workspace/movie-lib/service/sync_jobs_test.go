@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rohankarmacharya/movie-lib/models"
+	"github.com/rohankarmacharya/movie-lib/repository"
+	"github.com/rohankarmacharya/movie-lib/service"
+)
+
+// blockingSyncer runs until its context is done
+type blockingSyncer struct{}
+
+func (blockingSyncer) Sync(ctx context.Context, source string, progress service.ProgressFunc) (*models.SyncReport, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRecoverInterruptedSparesLiveJobs(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	ctx := t.Context()
+	const staleAfter = 100 * time.Millisecond
+	jobs := service.NewSyncJobs(repo, blockingSyncer{}, service.WithStaleAfter(staleAfter))
+	defer jobs.Shutdown(context.Background())
+
+	live, err := jobs.Start(ctx, service.SourcePopular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A job another process left running, with no heartbeat
+	dead := &models.SyncJob{Source: service.SourceChanges, State: models.SyncJobRunning}
+	if err := repo.CreateSyncJob(ctx, dead); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * staleAfter)
+	n, err := jobs.RecoverInterrupted(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("RecoverInterrupted = %d, %v; want 1", n, err)
+	}
+	if got, _ := repo.GetSyncJob(ctx, dead.ID); got == nil || got.State != models.SyncJobFailed {
+		t.Errorf("job without a heartbeat = %+v, want failed", got)
+	}
+	if got, _ := repo.GetSyncJob(ctx, live.ID); got == nil || got.State != models.SyncJobRunning {
+		t.Errorf("running job = %+v, want it left running", got)
+	}
+}